@@ -0,0 +1,602 @@
+package market
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+//归档文件格式
+type ArchiveFormat string
+
+//csv格式, 第一行为列名
+const ArchiveCsv ArchiveFormat = "csv"
+
+//parquet列存格式
+//文件关闭后才写入元数据, 每次打开分区都创建新的文件
+const ArchiveParquet ArchiveFormat = "parquet"
+
+//归档配置
+type ArchiveOptions struct {
+	Dir    string        //归档目录
+	Format ArchiveFormat //文件格式, 默认csv
+}
+
+//归档的一行数据
+//所有行情事件使用相同的列, 不使用的列为空
+//标记价格和指数价格使用price列, 持仓量使用size和amount列
+type archiveRow struct {
+	Type        string `parquet:"name=type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Organize    string `parquet:"name=organize, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Symbol      string `parquet:"name=symbol, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Timestamp   int64  `parquet:"name=timestamp, type=INT64"`   //交易所时间, unix纳秒
+	ReceivedAt  int64  `parquet:"name=received_at, type=INT64"` //本地接收时间, unix纳秒, 只有深度数据记录
+	Latency     int64  `parquet:"name=latency, type=INT64"`     //校正时钟偏移后的网络延迟, 纳秒, 只有深度数据记录
+	Seq         int64  `parquet:"name=seq, type=INT64"`
+	ExchangeSeq int64  `parquet:"name=exchange_seq, type=INT64"`
+	BidPrice    string `parquet:"name=bid_price, type=BYTE_ARRAY, convertedtype=UTF8"`
+	BidSize     string `parquet:"name=bid_size, type=BYTE_ARRAY, convertedtype=UTF8"`
+	AskPrice    string `parquet:"name=ask_price, type=BYTE_ARRAY, convertedtype=UTF8"`
+	AskSize     string `parquet:"name=ask_size, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Bids        string `parquet:"name=bids, type=BYTE_ARRAY, convertedtype=UTF8"` //买深度json
+	Asks        string `parquet:"name=asks, type=BYTE_ARRAY, convertedtype=UTF8"` //卖深度json
+	Side        string `parquet:"name=side, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Price       string `parquet:"name=price, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Size        string `parquet:"name=size, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Amount      string `parquet:"name=amount, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Rate        string `parquet:"name=rate, type=BYTE_ARRAY, convertedtype=UTF8"`           //当期资金费率
+	Estimated   string `parquet:"name=estimated_rate, type=BYTE_ARRAY, convertedtype=UTF8"` //预测资金费率
+	NextFunding int64  `parquet:"name=next_funding_time, type=INT64"`                       //下次结算时间, unix纳秒
+}
+
+//csv列名, 和archiveRow字段顺序相同
+var archiveColumns = []string{
+	"type", "organize", "symbol", "timestamp", "received_at", "latency", "seq", "exchange_seq",
+	"bid_price", "bid_size", "ask_price", "ask_size", "bids", "asks", "side", "price", "size",
+	"amount", "rate", "estimated_rate", "next_funding_time",
+}
+
+//事件转换为归档数据
+//断档, 停止推送和重连是本地事件, 返回nil不归档, 不支持的事件类型返回错误
+func newArchiveRow(data Eventer) (*archiveRow, error) {
+	b := data.Base()
+	row := &archiveRow{
+		Type:        string(b.Type),
+		Organize:    string(b.Organize),
+		Symbol:      b.Symbol,
+		Timestamp:   b.Timestamp.UnixNano(),
+		Seq:         int64(b.Seq),
+		ExchangeSeq: int64(b.ExchangeSeq),
+	}
+
+	switch e := data.(type) {
+	case *Marketer:
+		bids, err := json.Marshal(e.BuyDepth)
+		if err != nil {
+			return nil, err
+		}
+		asks, err := json.Marshal(e.SellDepth)
+		if err != nil {
+			return nil, err
+		}
+		row.ReceivedAt = e.ReceivedAt.UnixNano()
+		row.Latency = int64(e.Latency)
+		row.BidPrice, row.BidSize = e.BuyFirst, e.BuyFirstSize
+		row.AskPrice, row.AskSize = e.SellFirst, e.SellFirstSize
+		row.Bids, row.Asks = string(bids), string(asks)
+	case *BBO:
+		row.BidPrice, row.BidSize = e.BidPrice, e.BidSize
+		row.AskPrice, row.AskSize = e.AskPrice, e.AskSize
+	case *Liquidation:
+		row.Side, row.Price, row.Size = e.Side, e.Price, e.Size
+	case *FundingRate:
+		row.Rate, row.Estimated = e.FundingRate, e.EstimatedRate
+		if !e.NextFundingTime.IsZero() {
+			row.NextFunding = e.NextFundingTime.UnixNano()
+		}
+	case *MarkPrice:
+		row.Price = e.MarkPrice
+	case *IndexPrice:
+		row.Price = e.IndexPrice
+	case *OpenInterest:
+		row.Size, row.Amount = e.Volume, e.Amount
+	case *Gap, *Stale, *Reconnect:
+		return nil, nil
+	default:
+		return nil, errors.New("不支持的归档类型 " + string(b.Type))
+	}
+	return row, nil
+}
+
+//归档数据转换为事件
+func (r *archiveRow) eventer() (Eventer, error) {
+	event := Event{
+		Type:        EventType(r.Type),
+		Organize:    Organize(r.Organize),
+		Symbol:      r.Symbol,
+		Timestamp:   time.Unix(0, r.Timestamp),
+		Seq:         uint64(r.Seq),
+		ExchangeSeq: uint64(r.ExchangeSeq),
+	}
+
+	switch event.Type {
+	case DepthEvent:
+		m := &Marketer{
+			Organize:      event.Organize,
+			Symbol:        event.Symbol,
+			BuyFirst:      r.BidPrice,
+			BuyFirstSize:  r.BidSize,
+			SellFirst:     r.AskPrice,
+			SellFirstSize: r.AskSize,
+			Timestamp:     event.Timestamp,
+			Seq:           event.Seq,
+			ExchangeSeq:   event.ExchangeSeq,
+		}
+		if r.ReceivedAt != 0 {
+			m.ReceivedAt = time.Unix(0, r.ReceivedAt)
+			m.Temporize = m.ReceivedAt.Sub(m.Timestamp)
+			m.Latency = time.Duration(r.Latency)
+		}
+		if err := json.Unmarshal([]byte(r.Bids), &m.BuyDepth); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(r.Asks), &m.SellDepth); err != nil {
+			return nil, err
+		}
+		return m, nil
+	case BBOEvent:
+		return &BBO{Event: event, BidPrice: r.BidPrice, BidSize: r.BidSize, AskPrice: r.AskPrice, AskSize: r.AskSize}, nil
+	case LiquidationEvent:
+		return &Liquidation{Event: event, Side: r.Side, Price: r.Price, Size: r.Size}, nil
+	case FundingRateEvent:
+		f := &FundingRate{Event: event, FundingRate: r.Rate, EstimatedRate: r.Estimated}
+		if r.NextFunding != 0 {
+			f.NextFundingTime = time.Unix(0, r.NextFunding)
+		}
+		return f, nil
+	case MarkPriceEvent:
+		return &MarkPrice{Event: event, MarkPrice: r.Price}, nil
+	case IndexPriceEvent:
+		return &IndexPrice{Event: event, IndexPrice: r.Price}, nil
+	case OpenInterestEvent:
+		return &OpenInterest{Event: event, Volume: r.Size, Amount: r.Amount}, nil
+	}
+	return nil, errors.New("不支持的归档类型 " + r.Type)
+}
+
+//csv一行数据
+func (r *archiveRow) record() []string {
+	return []string{
+		r.Type, r.Organize, r.Symbol,
+		strconv.FormatInt(r.Timestamp, 10), strconv.FormatInt(r.ReceivedAt, 10), strconv.FormatInt(r.Latency, 10),
+		strconv.FormatInt(r.Seq, 10), strconv.FormatInt(r.ExchangeSeq, 10),
+		r.BidPrice, r.BidSize, r.AskPrice, r.AskSize, r.Bids, r.Asks, r.Side, r.Price, r.Size,
+		r.Amount, r.Rate, r.Estimated, strconv.FormatInt(r.NextFunding, 10),
+	}
+}
+
+//解析csv一行数据
+func parseArchiveRecord(record []string) (*archiveRow, error) {
+	if len(record) != len(archiveColumns) {
+		return nil, errors.New("归档列数错误")
+	}
+
+	var ints [5]int64
+	for i := range ints {
+		v, err := strconv.ParseInt(record[3+i], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ints[i] = v
+	}
+	next, err := strconv.ParseInt(record[20], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &archiveRow{
+		Type:        record[0],
+		Organize:    record[1],
+		Symbol:      record[2],
+		Timestamp:   ints[0],
+		ReceivedAt:  ints[1],
+		Latency:     ints[2],
+		Seq:         ints[3],
+		ExchangeSeq: ints[4],
+		BidPrice:    record[8],
+		BidSize:     record[9],
+		AskPrice:    record[10],
+		AskSize:     record[11],
+		Bids:        record[12],
+		Asks:        record[13],
+		Side:        record[14],
+		Price:       record[15],
+		Size:        record[16],
+		Amount:      record[17],
+		Rate:        record[18],
+		Estimated:   record[19],
+		NextFunding: next,
+	}, nil
+}
+
+//一个分区文件
+type archiveFile interface {
+	write(*archiveRow) error
+	flush() error
+	Close() error
+}
+
+//csv分区文件, 追加写入
+type csvArchiveFile struct {
+	file   *os.File
+	writer *csv.Writer
+}
+
+func openCsvArchiveFile(path string) (*csvArchiveFile, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	f := &csvArchiveFile{file: file, writer: csv.NewWriter(file)}
+	if info.Size() == 0 {
+		if err := f.writer.Write(archiveColumns); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (f *csvArchiveFile) write(row *archiveRow) error {
+	return f.writer.Write(row.record())
+}
+
+func (f *csvArchiveFile) flush() error {
+	f.writer.Flush()
+	return f.writer.Error()
+}
+
+func (f *csvArchiveFile) Close() error {
+	err := f.flush()
+	if e := f.file.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+//parquet分区文件
+type parquetArchiveFile struct {
+	file   *os.File
+	writer *writer.ParquetWriter
+}
+
+func openParquetArchiveFile(path string) (*parquetArchiveFile, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := writer.NewParquetWriterFromWriter(file, new(archiveRow), 1)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &parquetArchiveFile{file: file, writer: w}, nil
+}
+
+func (f *parquetArchiveFile) write(row *archiveRow) error {
+	return f.writer.Write(row)
+}
+
+//parquet只能在关闭时写入完整文件
+func (f *parquetArchiveFile) flush() error {
+	return nil
+}
+
+func (f *parquetArchiveFile) Close() error {
+	err := f.writer.WriteStop()
+	if e := f.file.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+//当前写入的分区
+type archivePartition struct {
+	day  string
+	file archiveFile
+}
+
+//行情数据归档
+//按交易所/币对/事件类型/日期(UTC, 交易所时间)分区写入
+//路径为 Dir/organize/symbol/type/2006-01-02.csv
+type Archive struct {
+	opts  ArchiveOptions
+	parts map[string]*archivePartition //key为交易所/币对/事件类型
+	lock  sync.Mutex
+}
+
+//创建归档
+func NewArchive(opts ArchiveOptions) (*Archive, error) {
+	if opts.Format == "" {
+		opts.Format = ArchiveCsv
+	}
+	if opts.Format != ArchiveCsv && opts.Format != ArchiveParquet {
+		return nil, errors.New("不支持的归档格式 " + string(opts.Format))
+	}
+
+	err := os.MkdirAll(opts.Dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Archive{
+		opts:  opts,
+		parts: make(map[string]*archivePartition),
+	}, nil
+}
+
+//设置行情数据归档
+//推送到pool的数据同时写入归档, nil停止归档, 停止后需要调用Close关闭文件
+func SetArchive(a *Archive) {
+	Manage.archive.Store(a)
+}
+
+//当前使用的归档
+func archiver() *Archive {
+	a, _ := Manage.archive.Load().(*Archive)
+	return a
+}
+
+//归档一个事件
+//写入失败只记录日志, 不影响行情处理
+func (a *Archive) save(data Eventer) {
+	if a == nil {
+		return
+	}
+
+	if err := a.Write(data); err != nil {
+		b := data.Base()
+		logger().Warn("归档数据失败", "organize", b.Organize, "symbol", b.Symbol, "type", b.Type, "err", err)
+	}
+}
+
+//分区目录
+//币对中的路径分隔符替换为下划线
+func archiveDir(dir string, organize Organize, symbol string, eventType EventType) string {
+	symbol = strings.NewReplacer("/", "_", "\\", "_").Replace(symbol)
+	return filepath.Join(dir, string(organize), symbol, string(eventType))
+}
+
+//写入一个事件
+//本地事件直接忽略, 不支持的事件类型返回错误
+func (a *Archive) Write(data Eventer) error {
+	if batch, ok := data.(eventBatch); ok {
+		for _, v := range batch {
+			if err := a.Write(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	row, err := newArchiveRow(data)
+	if err != nil || row == nil {
+		return err
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	b := data.Base()
+	day := b.Timestamp.UTC().Format("2006-01-02")
+	key := string(b.Organize) + "/" + b.Symbol + "/" + string(b.Type)
+	part := a.parts[key]
+	if part == nil || part.day != day {
+		if part != nil {
+			delete(a.parts, key)
+			if err := part.file.Close(); err != nil {
+				return err
+			}
+		}
+
+		file, err := a.open(archiveDir(a.opts.Dir, b.Organize, b.Symbol, b.Type), day)
+		if err != nil {
+			return err
+		}
+		part = &archivePartition{day: day, file: file}
+		a.parts[key] = part
+	}
+
+	return part.file.write(row)
+}
+
+//打开分区文件
+//csv追加到当天的文件, parquet每次使用新的序号, 例如2006-01-02.000.parquet
+func (a *Archive) open(dir string, day string) (archiveFile, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.opts.Format == ArchiveCsv {
+		file, err := openCsvArchiveFile(filepath.Join(dir, day+".csv"))
+		if err != nil {
+			return nil, err
+		}
+		return file, nil
+	}
+
+	for n := 0; ; n++ {
+		name := fmt.Sprintf("%s.%03d.parquet", day, n)
+		file, err := openParquetArchiveFile(filepath.Join(dir, name))
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return file, nil
+	}
+}
+
+//写入缓存到文件
+//parquet文件关闭后才可以读取
+func (a *Archive) Flush() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for _, part := range a.parts {
+		if err := part.file.flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//关闭所有分区文件
+//之后写入时重新打开
+func (a *Archive) Close() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	var err error
+	for key, part := range a.parts {
+		if e := part.file.Close(); err == nil {
+			err = e
+		}
+		delete(a.parts, key)
+	}
+	return err
+}
+
+//按时间范围读取归档
+//按分区日期和文件顺序返回[from, to)之间的数据
+type ArchiveReader struct {
+	files []string
+	from  time.Time
+	to    time.Time
+	rows  []*archiveRow //当前文件还没有返回的数据
+}
+
+//打开归档读取
+//读取一个交易所币对的一种事件, 支持csv和parquet文件
+func OpenArchiveReader(dir string, organize Organize, symbol string, eventType EventType, from, to time.Time) (*ArchiveReader, error) {
+	r := &ArchiveReader{from: from, to: to}
+	dir = archiveDir(dir, organize, symbol, eventType)
+
+	for day := from.UTC().Truncate(24 * time.Hour); day.Before(to); day = day.Add(24 * time.Hour) {
+		files, err := filepath.Glob(filepath.Join(dir, day.Format("2006-01-02")+".*"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+		r.files = append(r.files, files...)
+	}
+	return r, nil
+}
+
+//读取下一个事件
+//返回*Marketer, *BBO或者*Liquidation, 读取完成返回io.EOF
+func (r *ArchiveReader) Next() (Eventer, error) {
+	for {
+		for len(r.rows) > 0 {
+			row := r.rows[0]
+			r.rows = r.rows[1:]
+
+			t := time.Unix(0, row.Timestamp)
+			if t.Before(r.from) || !t.Before(r.to) {
+				continue
+			}
+			return row.eventer()
+		}
+
+		if len(r.files) == 0 {
+			return nil, io.EOF
+		}
+
+		rows, err := readArchiveFile(r.files[0])
+		if err != nil {
+			return nil, err
+		}
+		r.files = r.files[1:]
+		r.rows = rows
+	}
+}
+
+//读取一个分区文件的所有数据
+func readArchiveFile(path string) ([]*archiveRow, error) {
+	if strings.HasSuffix(path, "."+string(ArchiveParquet)) {
+		return readParquetArchiveFile(path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]*archiveRow, 0, len(records))
+	for i, record := range records {
+		if i == 0 && len(record) > 0 && record[0] == archiveColumns[0] {
+			continue
+		}
+
+		row, err := parseArchiveRecord(record)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func readParquetArchiveFile(path string) ([]*archiveRow, error) {
+	file, err := local.NewLocalFileReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	pr, err := reader.NewParquetReader(file, new(archiveRow), 1)
+	if err != nil {
+		return nil, err
+	}
+	defer pr.ReadStop()
+
+	data := make([]archiveRow, pr.GetNumRows())
+	if err := pr.Read(&data); err != nil {
+		return nil, err
+	}
+
+	rows := make([]*archiveRow, len(data))
+	for i := range data {
+		rows[i] = &data[i]
+	}
+	return rows, nil
+}
@@ -0,0 +1,166 @@
+package market
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+//读取时间范围内的所有事件
+func readArchive(t *testing.T, dir string, organize Organize, symbol string, eventType EventType, from, to time.Time) []Eventer {
+	t.Helper()
+
+	r, err := OpenArchiveReader(dir, organize, symbol, eventType, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events []Eventer
+	for {
+		e, err := r.Next()
+		if err == io.EOF {
+			return events
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, e)
+	}
+}
+
+func TestArchive_ReadRange(t *testing.T) {
+	for _, format := range []ArchiveFormat{ArchiveCsv, ArchiveParquet} {
+		dir := t.TempDir()
+		a, err := NewArchive(ArchiveOptions{Dir: dir, Format: format})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		start := time.Date(2020, 3, 1, 23, 59, 59, 0, time.UTC)
+		for i := 0; i < 4; i++ {
+			ts := start.Add(time.Duration(i) * 500 * time.Millisecond)
+			err := a.Write(&Marketer{
+				Organize:   OkEx,
+				Symbol:     "BTC-USDT",
+				BuyFirst:   "9000",
+				BuyDepth:   Depth{{"9000", "1"}, {"8999", "2"}},
+				SellFirst:  "9001",
+				SellDepth:  Depth{{"9001", "3"}},
+				Timestamp:  ts,
+				ReceivedAt: ts.Add(20 * time.Millisecond),
+				Latency:    15 * time.Millisecond,
+				Seq:        uint64(i + 1),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		a.Write(&BBO{Event: Event{Type: BBOEvent, Organize: OkEx, Symbol: "BTC-USDT", Timestamp: start}, BidPrice: "9000", AskPrice: "9001"})
+		a.Write(eventBatch{
+			&Liquidation{Event: Event{Type: LiquidationEvent, Organize: HuoBi, Symbol: "BTC-USD", Timestamp: start}, Side: "sell", Price: "8990", Size: "10"},
+		})
+		a.Write(&MarkPrice{Event: Event{Type: MarkPriceEvent, Organize: OkEx, Symbol: "BTC-USDT", Timestamp: start}, MarkPrice: "9000.5"})
+		a.Write(&IndexPrice{Event: Event{Type: IndexPriceEvent, Organize: OkEx, Symbol: "BTC-USDT", Timestamp: start}, IndexPrice: "9000.1"})
+		a.Write(&FundingRate{Event: Event{Type: FundingRateEvent, Organize: OkEx, Symbol: "BTC-USDT", Timestamp: start},
+			FundingRate: "0.0001", EstimatedRate: "0.0002", NextFundingTime: start.Add(time.Hour)})
+		a.Write(&OpenInterest{Event: Event{Type: OpenInterestEvent, Organize: OkEx, Symbol: "BTC-USDT", Timestamp: start}, Volume: "1000", Amount: "10.5"})
+		if err := a.Write(&Gap{Event: Event{Type: GapEvent, Organize: OkEx, Symbol: "BTC-USDT", Timestamp: start}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := a.Write(&Event{Type: "xxx", Organize: OkEx, Symbol: "BTC-USDT", Timestamp: start}); err == nil {
+			t.Fatal("不支持的类型应该返回错误")
+		}
+		if err := a.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		days, _ := filepath.Glob(filepath.Join(dir, "okex", "BTC-USDT", "depth", "*"))
+		if len(days) != 2 {
+			t.Fatal(format, days)
+		}
+
+		events := readArchive(t, dir, OkEx, "BTC-USDT", DepthEvent, start.Add(time.Second), start.Add(time.Hour))
+		if len(events) != 2 {
+			t.Fatal(format, events)
+		}
+		m := events[0].(*Marketer)
+		if m.Seq != 3 || !m.Timestamp.Equal(start.Add(time.Second)) || m.BuyDepth[1][1] != "2" || m.SellDepth[0][0] != "9001" ||
+			m.Temporize != 20*time.Millisecond || m.Latency != 15*time.Millisecond {
+			t.Fatal(format, m)
+		}
+
+		if events := readArchive(t, dir, OkEx, "BTC-USDT", BBOEvent, start, start.Add(time.Second)); len(events) != 1 || events[0].(*BBO).AskPrice != "9001" {
+			t.Fatal(format, events)
+		}
+		if events := readArchive(t, dir, HuoBi, "BTC-USD", LiquidationEvent, start, start.Add(time.Second)); len(events) != 1 || events[0].(*Liquidation).Size != "10" {
+			t.Fatal(format, events)
+		}
+		if events := readArchive(t, dir, OkEx, "BTC-USDT", MarkPriceEvent, start, start.Add(time.Second)); len(events) != 1 || events[0].(*MarkPrice).MarkPrice != "9000.5" {
+			t.Fatal(format, events)
+		}
+		if events := readArchive(t, dir, OkEx, "BTC-USDT", IndexPriceEvent, start, start.Add(time.Second)); len(events) != 1 || events[0].(*IndexPrice).IndexPrice != "9000.1" {
+			t.Fatal(format, events)
+		}
+		events = readArchive(t, dir, OkEx, "BTC-USDT", FundingRateEvent, start, start.Add(time.Second))
+		if len(events) != 1 {
+			t.Fatal(format, events)
+		}
+		if f := events[0].(*FundingRate); f.FundingRate != "0.0001" || f.EstimatedRate != "0.0002" || !f.NextFundingTime.Equal(start.Add(time.Hour)) {
+			t.Fatal(format, events)
+		}
+		if events := readArchive(t, dir, OkEx, "BTC-USDT", OpenInterestEvent, start, start.Add(time.Second)); len(events) != 1 || events[0].(*OpenInterest).Amount != "10.5" {
+			t.Fatal(format, events)
+		}
+		if gaps, _ := filepath.Glob(filepath.Join(dir, "okex", "BTC-USDT", "gap")); len(gaps) != 0 {
+			t.Fatal(format, gaps)
+		}
+	}
+}
+
+func TestArchive_Reopen(t *testing.T) {
+	for _, format := range []ArchiveFormat{ArchiveCsv, ArchiveParquet} {
+		dir := t.TempDir()
+		a, err := NewArchive(ArchiveOptions{Dir: dir, Format: format})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ts := time.Date(2020, 3, 1, 8, 0, 0, 0, time.UTC)
+		for i := 0; i < 3; i++ {
+			a.Write(&Marketer{Organize: HuoBi, Symbol: "ethusdt", Timestamp: ts.Add(time.Duration(i) * time.Second), Seq: uint64(i)})
+			a.Close()
+		}
+
+		events := readArchive(t, dir, HuoBi, "ethusdt", DepthEvent, ts, ts.Add(time.Minute))
+		if len(events) != 3 || events[2].(*Marketer).Seq != 2 {
+			t.Fatal(format, events)
+		}
+	}
+}
+
+func TestArchive_Dispatch(t *testing.T) {
+	dir := t.TempDir()
+	a, err := NewArchive(ArchiveOptions{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetArchive(a)
+	defer SetArchive(nil)
+
+	received := time.Date(2020, 3, 1, 8, 0, 0, 0, time.UTC)
+	w := newWorkerGroup(context.Background(), newOkEx).workers[0]
+	msg := []byte(okexDepthFrame(received, "ADA-USDT", "0.05").Data)
+	data, err := w.handler.formatMsgHandle(msg, w)
+	if err := w.dispatch(data, err, received, 0, msg); err != nil {
+		t.Fatal(err)
+	}
+	SetArchive(nil)
+	a.Close()
+
+	events := readArchive(t, dir, OkEx, "ADA-USDT", DepthEvent, received.Add(-time.Minute), received)
+	if len(events) != 1 || events[0].(*Marketer).BuyFirst != "0.05" {
+		t.Fatal(events)
+	}
+}
@@ -0,0 +1,80 @@
+package market
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"time"
+)
+
+//ws重连退避策略
+type Backoff struct {
+	Min         time.Duration //第一次重试等待时间
+	Max         time.Duration //最大等待时间
+	Factor      float64       //每次重试等待时间的倍数
+	Jitter      float64       //随机抖动比例, 0.2表示上下浮动20%
+	MaxAttempts int           //最大连续重试次数, 0表示不限制
+}
+
+//默认退避策略
+//1秒开始, 每次翻倍, 最多等待1分钟
+var DefaultBackoff = &Backoff{
+	Min:    time.Second,
+	Max:    time.Minute,
+	Factor: 2,
+	Jitter: 0.2,
+}
+
+//第attempt次重试前的等待时间
+//attempt从1开始, 加上抖动后不超过Max
+func (b *Backoff) delay(attempt int) time.Duration {
+	d := float64(b.Min) * math.Pow(b.Factor, float64(attempt-1))
+	if b.Jitter > 0 {
+		d += d * b.Jitter * (rand.Float64()*2 - 1)
+	}
+
+	if d > float64(b.Max) {
+		d = float64(b.Max)
+	}
+	return time.Duration(d)
+}
+
+//连接状态
+type ConnState string
+
+//连接失败, 等待重试
+const ConnRetrying ConnState = "retrying"
+
+//连接成功
+const ConnConnected ConnState = "connected"
+
+//超过最大重试次数或者context关闭, 放弃连接
+const ConnFailed ConnState = "failed"
+
+//重连事件
+//每次连接尝试都会推送
+type Reconnect struct {
+	Event
+	State   ConnState     `json:"state"`           //连接状态
+	Attempt int           `json:"attempt"`         //第几次尝试, 从1开始
+	Delay   time.Duration `json:"delay,omitempty"` //下次重试等待时间
+	Err     string        `json:"err,omitempty"`   //连接失败原因
+}
+
+//按JsonMillisecond序列化
+func (e *Reconnect) MarshalJSON() ([]byte, error) {
+	type alias Reconnect
+	if !JsonMillisecond {
+		return json.Marshal((*alias)(e))
+	}
+
+	return json.Marshal(&struct {
+		*alias
+		Timestamp int64 `json:"timestamp,omitempty"`
+		Delay     int64 `json:"delay,omitempty"`
+	}{
+		alias:     (*alias)(e),
+		Timestamp: millisecond(e.Timestamp),
+		Delay:     e.Delay.Milliseconds(),
+	})
+}
@@ -0,0 +1,21 @@
+package market
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff_Delay(t *testing.T) {
+	b := &Backoff{Min: time.Second, Max: time.Minute, Factor: 2, Jitter: 0.2}
+
+	if d := b.delay(1); d < 800*time.Millisecond || d > 1200*time.Millisecond {
+		t.Fatal(d)
+	}
+
+	//超过Max后加上抖动也不超过Max
+	for i := 0; i < 100; i++ {
+		if d := b.delay(10 + i); d > b.Max || d < 48*time.Second {
+			t.Fatal(i, d)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package market
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//时钟同步间隔
+var ClockSyncInterval = time.Minute
+
+//交易所服务器时间接口
+//可以替换为其他地址, 删除后该交易所不校正时钟偏移
+var ClockUrls = map[Organize]string{
+	OkEx:  okexRestUrl + "/api/general/v3/time",
+	HuoBi: huoBiRestUrl + "/api/v1/timestamp",
+}
+
+//每个交易所保留的同步样本数量
+const clockSamples = 8
+
+//交易所时钟偏移估计
+//每次同步记录偏移和往返时间, 使用往返时间最短的样本
+type clock struct {
+	samples []clockSample
+	offset  int64 //服务器时间减去本地时间(纳秒), 原子操作
+	lock    sync.Mutex
+}
+
+//一次同步的结果
+type clockSample struct {
+	offset time.Duration //服务器时间减去本地时间
+	rtt    time.Duration //请求往返时间
+}
+
+var clocks = map[Organize]*clock{
+	OkEx:  {},
+	HuoBi: {},
+}
+
+//返回交易所的时钟偏移
+//服务器时间减去本地时间, 还没有同步时返回0
+func ClockOffset(organize Organize) time.Duration {
+	c, ok := clocks[organize]
+	if !ok {
+		return 0
+	}
+	return time.Duration(atomic.LoadInt64(&c.offset))
+}
+
+//记录一次同步结果
+//假设请求和返回的网络延迟相同, 服务器时间对应本地请求时间的中点
+func (c *clock) add(sent, received time.Time, server time.Duration) {
+	rtt := received.Sub(sent)
+	local := time.Duration(sent.UnixNano()) + rtt/2
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.samples = append(c.samples, clockSample{offset: server - local, rtt: rtt})
+	if len(c.samples) > clockSamples {
+		c.samples = c.samples[1:]
+	}
+
+	best := c.samples[0]
+	for _, v := range c.samples[1:] {
+		if v.rtt < best.rtt {
+			best = v
+		}
+	}
+	atomic.StoreInt64(&c.offset, int64(best.offset))
+}
+
+//定时同步所有交易所的时钟偏移
+//context关闭后结束
+func syncClocks(ctx context.Context) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	for {
+		for organize, url := range ClockUrls {
+			c, ok := clocks[organize]
+			if !ok || url == "" {
+				continue
+			}
+			if err := c.sync(ctx, client, url); err != nil {
+				logger().Warn("时钟同步失败", "organize", organize, "url", url, "err", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.NewTimer(ClockSyncInterval).C:
+		}
+	}
+}
+
+//请求一次服务器时间
+func (c *clock) sync(ctx context.Context, client *http.Client, url string) error {
+	sent := time.Now()
+	body, err := httpGet(ctx, client, url)
+	received := time.Now()
+	if err != nil {
+		return err
+	}
+
+	server, err := parseServerTime(body)
+	if err != nil {
+		return err
+	}
+
+	c.add(sent, received, server)
+	return nil
+}
+
+//服务器时间返回结构体
+//okex: {"iso":"...","epoch":"1420674445.201"}
+//火币: {"status":"ok","ts":1420674445201}
+type serverTime struct {
+	Epoch string      `json:"epoch"` //秒, 小数部分为毫秒
+	Ts    json.Number `json:"ts"`    //毫秒
+	Data  json.Number `json:"data"`  //毫秒
+}
+
+//解析服务器时间, 返回unix纳秒
+func parseServerTime(body []byte) (time.Duration, error) {
+	t := &serverTime{}
+	err := json.Unmarshal(body, t)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case t.Epoch != "":
+		s, err := strconv.ParseFloat(t.Epoch, 64)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(s * float64(time.Second)), nil
+	case t.Ts != "":
+		ms, err := t.Ts.Int64()
+		return time.Duration(ms) * time.Millisecond, err
+	case t.Data != "":
+		ms, err := t.Data.Int64()
+		return time.Duration(ms) * time.Millisecond, err
+	}
+
+	return 0, errors.New("序列化服务器时间错误")
+}
@@ -0,0 +1,52 @@
+package market
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseServerTime(t *testing.T) {
+	for body, want := range map[string]time.Duration{
+		`{"iso":"2015-01-07T23:47:25.201Z","epoch":"1420674445.201"}`: 1420674445201 * time.Millisecond,
+		`{"status":"ok","ts":1420674445201}`:                          1420674445201 * time.Millisecond,
+		`{"status":"ok","data":1420674445201}`:                        1420674445201 * time.Millisecond,
+	} {
+		got, err := parseServerTime([]byte(body))
+		if err != nil || (got-want).Abs() > time.Millisecond {
+			t.Fatal(body, got, err)
+		}
+	}
+}
+
+func TestClock_Sync(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now().Add(2*time.Second).UnixNano() / 1e6
+		w.Write([]byte(`{"status":"ok","ts":` + strconv.FormatInt(ts, 10) + `}`))
+	}))
+	defer server.Close()
+
+	//使用独立的clock, 不替换全局clocks, 避免和其他测试遗留的worker竞争
+	c := &clock{}
+	err := c.sync(context.Background(), server.Client(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset := time.Duration(atomic.LoadInt64(&c.offset))
+	if (offset - 2*time.Second).Abs() > 100*time.Millisecond {
+		t.Fatal(offset)
+	}
+
+	m := NewTestMarketer()
+	m.Organize = HuoBi
+	received := time.Now()
+	m.Timestamp = received.Add(2*time.Second - 20*time.Millisecond)
+	m.stamp(received, offset)
+	if m.Temporize > -1900*time.Millisecond || m.Latency < 0 || m.Latency > 120*time.Millisecond {
+		t.Fatal(m.Temporize, m.Latency)
+	}
+}
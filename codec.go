@@ -0,0 +1,414 @@
+package market
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//二进制编码版本
+//编码的第一个字节, 修改格式时递增, 解码时按版本解析
+//版本号不会是'{', 消费方可以据此区分json和二进制编码
+const CodecVersion byte = 1
+
+//价格和数量最大小数位数
+const codecMaxScale = 18
+
+//币对自动增加的小数位数上限
+//小数位数过大时价格转换为定点整数会溢出, 超过的数据只在该条编码使用自己的小数位数
+const codecStickyScale = 12
+
+//二进制编码可选字段标记
+const (
+	codecBuyFirst = 1 << iota
+	codecBuyFirstSize
+	codecSellFirst
+	codecSellFirstSize
+	codecTimestamp
+	codecReceivedAt
+)
+
+var errCodecShort = errors.New("编码数据长度错误")
+
+//币对的定点数小数位数
+type codecScale struct {
+	price uint8
+	size  uint8
+}
+
+type codecKey struct {
+	organize Organize
+	symbol   string
+}
+
+//深度行情二进制编码
+//价格和数量转换为定点整数, 每个币对使用相同的小数位数, 小数位数写入每条编码, 解码不需要状态
+//深度第一档价格为原值, 之后为和上一档的差值, 整数都使用varint编码
+//
+//版本1格式:
+//
+//	版本(1字节) 交易所(uvarint长度+数据) 币对(uvarint长度+数据) 可选字段标记(1字节) 价格小数位数(1字节) 数量小数位数(1字节)
+//	交易所时间(varint unix纳秒) 本地接收时间(varint, 和交易所时间的差值) 网络延迟(varint纳秒) 校正后的网络延迟(varint纳秒)
+//	本地序号(uvarint) 交易所序号(uvarint) 买一价格 买一数量 卖一价格 卖一数量(varint, 只写入标记的字段)
+//	买深度档数(uvarint) 每档价格(varint, 差值) 数量(varint) 卖深度档数(uvarint) 每档价格(varint, 差值) 数量(varint)
+type Codec struct {
+	scales map[codecKey]codecScale
+	lock   sync.Mutex
+}
+
+//创建二进制编码
+func NewCodec() *Codec {
+	return &Codec{
+		scales: make(map[codecKey]codecScale),
+	}
+}
+
+//默认的二进制编码, EncodeMarketer使用
+var defaultCodec = NewCodec()
+
+//设置币对的小数位数
+//没有设置时使用数据中出现过的最大小数位数, 数据的小数位数超过设置时自动增加, 最多增加到codecStickyScale
+func (c *Codec) SetScale(organize Organize, symbol string, price int, size int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.scales[codecKey{organize: organize, symbol: symbol}] = codecScale{price: uint8(price), size: uint8(size)}
+}
+
+//币对的小数位数
+//和数据中的小数位数比较, 取较大的值
+//币对记录的小数位数最多自动增加到codecStickyScale
+func (c *Codec) scale(m *Marketer, s codecScale) codecScale {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	key := codecKey{organize: m.Organize, symbol: m.Symbol}
+	sticky := c.scales[key]
+	if s.price > sticky.price && sticky.price < codecStickyScale {
+		sticky.price = s.price
+		if sticky.price > codecStickyScale {
+			sticky.price = codecStickyScale
+		}
+	}
+	if s.size > sticky.size && sticky.size < codecStickyScale {
+		sticky.size = s.size
+		if sticky.size > codecStickyScale {
+			sticky.size = codecStickyScale
+		}
+	}
+	c.scales[key] = sticky
+
+	return maxScale(maxScale(sticky, s.price, false), s.size, true)
+}
+
+//使用默认编码编码深度行情
+func EncodeMarketer(m *Marketer) ([]byte, error) {
+	return defaultCodec.AppendMarketer(nil, m)
+}
+
+//追加编码后的深度行情
+//币对的小数位数转换溢出时使用数据自己的小数位数, 出错时返回原来的b
+func (c *Codec) AppendMarketer(b []byte, m *Marketer) ([]byte, error) {
+	var flags byte
+	var s codecScale
+	firsts := []string{m.BuyFirst, m.BuyFirstSize, m.SellFirst, m.SellFirstSize}
+	for k, v := range firsts {
+		if v == "" {
+			continue
+		}
+		flags |= 1 << k
+		_, scale, err := parseFixed(v)
+		if err != nil {
+			return b, err
+		}
+		s = maxScale(s, scale, k%2 == 1)
+	}
+	for _, d := range []Depth{m.BuyDepth, m.SellDepth} {
+		for _, v := range d {
+			_, price, err := parseFixed(v[0])
+			if err != nil {
+				return b, err
+			}
+			_, size, err := parseFixed(v[1])
+			if err != nil {
+				return b, err
+			}
+			s = maxScale(maxScale(s, price, false), size, true)
+		}
+	}
+	scale := c.scale(m, s)
+	out, err := appendMarketer(b, m, flags, scale)
+	if err != nil && scale != s {
+		out, err = appendMarketer(b, m, flags, s)
+	}
+	return out, err
+}
+
+//使用指定的小数位数编码
+func appendMarketer(b []byte, m *Marketer, flags byte, s codecScale) ([]byte, error) {
+	start := len(b)
+	firsts := []string{m.BuyFirst, m.BuyFirstSize, m.SellFirst, m.SellFirstSize}
+
+	var timestamp, received int64
+	if !m.Timestamp.IsZero() {
+		flags |= codecTimestamp
+		timestamp = m.Timestamp.UnixNano()
+	}
+	if !m.ReceivedAt.IsZero() {
+		flags |= codecReceivedAt
+		received = m.ReceivedAt.UnixNano()
+	}
+
+	b = append(b, CodecVersion)
+	b = binary.AppendUvarint(b, uint64(len(m.Organize)))
+	b = append(b, m.Organize...)
+	b = binary.AppendUvarint(b, uint64(len(m.Symbol)))
+	b = append(b, m.Symbol...)
+	b = append(b, flags, s.price, s.size)
+	b = binary.AppendVarint(b, timestamp)
+	b = binary.AppendVarint(b, received-timestamp)
+	b = binary.AppendVarint(b, int64(m.Temporize))
+	b = binary.AppendVarint(b, int64(m.Latency))
+	b = binary.AppendUvarint(b, m.Seq)
+	b = binary.AppendUvarint(b, m.ExchangeSeq)
+
+	for k, v := range firsts {
+		if v == "" {
+			continue
+		}
+		scale := s.price
+		if k%2 == 1 {
+			scale = s.size
+		}
+		n, err := toFixed(v, scale)
+		if err != nil {
+			return b[:start], err
+		}
+		b = binary.AppendVarint(b, n)
+	}
+
+	for _, d := range []Depth{m.BuyDepth, m.SellDepth} {
+		b = binary.AppendUvarint(b, uint64(len(d)))
+		var last int64
+		for _, v := range d {
+			price, err := toFixed(v[0], s.price)
+			if err != nil {
+				return b[:start], err
+			}
+			size, err := toFixed(v[1], s.size)
+			if err != nil {
+				return b[:start], err
+			}
+			b = binary.AppendVarint(b, price-last)
+			b = binary.AppendVarint(b, size)
+			last = price
+		}
+	}
+	return b, nil
+}
+
+//解码深度行情
+//价格和数量转换为去掉末尾0的小数字符串
+func DecodeMarketer(b []byte) (*Marketer, error) {
+	if len(b) == 0 {
+		return nil, errCodecShort
+	}
+	if b[0] != CodecVersion {
+		return nil, errors.New("不支持的编码版本 " + strconv.Itoa(int(b[0])))
+	}
+
+	d := &codecDecoder{b: b[1:]}
+	m := &Marketer{
+		Organize: Organize(d.bytes()),
+		Symbol:   string(d.bytes()),
+	}
+	flags, priceScale, sizeScale := d.byte(), d.byte(), d.byte()
+	if priceScale > codecMaxScale || sizeScale > codecMaxScale {
+		return nil, errors.New("小数位数错误")
+	}
+
+	timestamp := d.varint()
+	received := timestamp + d.varint()
+	if flags&codecTimestamp != 0 {
+		m.Timestamp = time.Unix(0, timestamp)
+	}
+	if flags&codecReceivedAt != 0 {
+		m.ReceivedAt = time.Unix(0, received)
+	}
+	m.Temporize = time.Duration(d.varint())
+	m.Latency = time.Duration(d.varint())
+	m.Seq = d.uvarint()
+	m.ExchangeSeq = d.uvarint()
+
+	for k, v := range []*string{&m.BuyFirst, &m.BuyFirstSize, &m.SellFirst, &m.SellFirstSize} {
+		if flags&(1<<k) == 0 {
+			continue
+		}
+		scale := priceScale
+		if k%2 == 1 {
+			scale = sizeScale
+		}
+		*v = formatFixed(d.varint(), scale)
+	}
+
+	for _, depth := range []*Depth{&m.BuyDepth, &m.SellDepth} {
+		n := d.uvarint()
+		if n > uint64(len(d.b)) {
+			return nil, errCodecShort
+		}
+		if n == 0 {
+			continue
+		}
+
+		*depth = make(Depth, n)
+		var last int64
+		for k := range *depth {
+			last += d.varint()
+			(*depth)[k] = [2]string{formatFixed(last, priceScale), formatFixed(d.varint(), sizeScale)}
+		}
+	}
+
+	if d.err != nil {
+		return nil, d.err
+	}
+	return m, nil
+}
+
+//二进制解码
+//出错后返回零值, 最后检查err
+type codecDecoder struct {
+	b   []byte
+	err error
+}
+
+func (d *codecDecoder) byte() byte {
+	if len(d.b) < 1 {
+		d.err = errCodecShort
+		return 0
+	}
+	v := d.b[0]
+	d.b = d.b[1:]
+	return v
+}
+
+func (d *codecDecoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.err = errCodecShort
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *codecDecoder) varint() int64 {
+	v, n := binary.Varint(d.b)
+	if n <= 0 {
+		d.err = errCodecShort
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *codecDecoder) bytes() []byte {
+	n := d.uvarint()
+	if n > uint64(len(d.b)) {
+		d.err = errCodecShort
+		return nil
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+//取较大的小数位数
+func maxScale(s codecScale, scale uint8, size bool) codecScale {
+	if size && scale > s.size {
+		s.size = scale
+	}
+	if !size && scale > s.price {
+		s.price = scale
+	}
+	return s
+}
+
+//解析小数字符串为定点整数
+//支持科学计数法, 返回去掉末尾0后的整数和小数位数
+func parseFixed(s string) (int64, uint8, error) {
+	mantissa, exp := s, 0
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.Atoi(s[i+1:])
+		if err != nil {
+			return 0, 0, errors.New("数字格式错误 " + s)
+		}
+		mantissa, exp = s[:i], e
+	}
+
+	scale := 0
+	if i := strings.IndexByte(mantissa, '.'); i >= 0 {
+		frac := strings.TrimRight(mantissa[i+1:], "0")
+		scale = len(frac)
+		mantissa = mantissa[:i] + frac
+	}
+	scale -= exp
+
+	n, err := strconv.ParseInt(mantissa, 10, 64)
+	if err != nil {
+		return 0, 0, errors.New("数字格式错误 " + s)
+	}
+	for ; scale > 0 && n%10 == 0 && n != 0; scale-- {
+		n /= 10
+	}
+	for ; scale < 0; scale++ {
+		if n > 1<<63/10 || n < -1<<63/10 {
+			return 0, 0, errors.New("数字超出范围 " + s)
+		}
+		n *= 10
+	}
+	if scale > codecMaxScale {
+		return 0, 0, errors.New("小数位数超出范围 " + s)
+	}
+	return n, uint8(scale), nil
+}
+
+//转换为指定小数位数的定点整数
+func toFixed(s string, scale uint8) (int64, error) {
+	n, from, err := parseFixed(s)
+	if err != nil {
+		return 0, err
+	}
+	for ; from < scale; from++ {
+		if n > 1<<63/10 || n < -1<<63/10 {
+			return 0, errors.New("数字超出范围 " + s)
+		}
+		n *= 10
+	}
+	return n, nil
+}
+
+//定点整数转换为小数字符串
+//去掉末尾的0
+func formatFixed(n int64, scale uint8) string {
+	s := strconv.FormatInt(n, 10)
+	if scale == 0 {
+		return s
+	}
+
+	sign := ""
+	if n < 0 {
+		sign, s = "-", s[1:]
+	}
+	if len(s) <= int(scale) {
+		s = strings.Repeat("0", int(scale)-len(s)+1) + s
+	}
+	i := len(s) - int(scale)
+	frac := strings.TrimRight(s[i:], "0")
+	if frac == "" {
+		return sign + s[:i]
+	}
+	return sign + s[:i] + "." + frac
+}
@@ -0,0 +1,143 @@
+package market
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCodec_Marketer(t *testing.T) {
+	ts := time.Unix(1583049600, 123456789)
+	m := &Marketer{
+		Organize:      HuoBi,
+		Symbol:        "btcusdt",
+		BuyFirst:      "9000.5",
+		BuyFirstSize:  "0.0012",
+		SellFirst:     "9001",
+		SellFirstSize: "1e-05",
+		BuyDepth:      Depth{{"9000.5", "0.0012"}, {"8999.25", "3"}, {"8990", "120.5"}},
+		SellDepth:     Depth{{"9001", "1e-05"}, {"9001.75", "0.5"}},
+		Timestamp:     ts,
+		Temporize:     15 * time.Millisecond,
+		Latency:       12 * time.Millisecond,
+		ReceivedAt:    ts.Add(15 * time.Millisecond),
+		Seq:           42,
+		ExchangeSeq:   1000001,
+	}
+
+	b, err := EncodeMarketer(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b[0] != CodecVersion || len(b) >= len(m.MarshalJson())/3 {
+		t.Fatal(len(b), len(m.MarshalJson()))
+	}
+
+	got, err := DecodeMarketer(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := *m
+	want.SellFirstSize = "0.00001"
+	want.SellDepth = Depth{{"9001", "0.00001"}, {"9001.75", "0.5"}}
+	if !reflect.DeepEqual(got, &want) {
+		t.Fatal(got)
+	}
+
+	//空字段和零值时间不编码
+	got, err = DecodeMarketer(mustEncode(t, &Marketer{Organize: OkEx, Symbol: "ETH-USDT", SellFirst: "230.1"}))
+	if err != nil || !reflect.DeepEqual(got, &Marketer{Organize: OkEx, Symbol: "ETH-USDT", SellFirst: "230.1"}) {
+		t.Fatal(got, err)
+	}
+}
+
+func mustEncode(t *testing.T, m *Marketer) []byte {
+	t.Helper()
+
+	b, err := NewCodec().AppendMarketer(nil, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestCodec_Scale(t *testing.T) {
+	c := NewCodec()
+	c.SetScale(OkEx, "BTC-USDT", 1, 8)
+
+	b, err := c.AppendMarketer(nil, &Marketer{Organize: OkEx, Symbol: "BTC-USDT", BuyFirst: "9000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//价格和数量小数位数在版本, 交易所, 币对和可选字段标记之后
+	head := 1 + 1 + len(OkEx) + 1 + len("BTC-USDT") + 1
+	if b[head] != 1 || b[head+1] != 8 {
+		t.Fatal(b)
+	}
+
+	//超过设置的小数位数时增加, 之后保持不变
+	c.AppendMarketer(nil, &Marketer{Organize: OkEx, Symbol: "BTC-USDT", BuyFirst: "9000.123"})
+	b, _ = c.AppendMarketer(b[:0], &Marketer{Organize: OkEx, Symbol: "BTC-USDT", BuyFirst: "9000"})
+	if m, err := DecodeMarketer(b); err != nil || m.BuyFirst != "9000" || c.scales[codecKey{OkEx, "BTC-USDT"}].price != 3 {
+		t.Fatal(m, err)
+	}
+
+	if _, err := c.AppendMarketer(nil, &Marketer{Organize: OkEx, Symbol: "BTC-USDT", BuyFirst: "abc"}); err == nil {
+		t.Fatal("没有返回错误")
+	}
+}
+
+func TestCodec_ScaleOverflow(t *testing.T) {
+	c := NewCodec()
+
+	//自动增加的小数位数不超过codecStickyScale, 超过的数据使用自己的小数位数
+	b, err := c.AppendMarketer(nil, &Marketer{Organize: OkEx, Symbol: "SHIB-USDT", BuyFirst: "0.000000000000001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, err := DecodeMarketer(b); err != nil || m.BuyFirst != "0.000000000000001" || c.scales[codecKey{OkEx, "SHIB-USDT"}].price != codecStickyScale {
+		t.Fatal(m, err)
+	}
+
+	//币对的小数位数转换溢出时使用数据自己的小数位数
+	b, err = c.AppendMarketer(b[:0], &Marketer{Organize: OkEx, Symbol: "SHIB-USDT", BuyFirst: "100000000", BuyDepth: Depth{{"100000000", "1"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, err := DecodeMarketer(b); err != nil || m.BuyFirst != "100000000" || m.BuyDepth[0][0] != "100000000" {
+		t.Fatal(m, err)
+	}
+}
+
+func TestCodec_DecodeError(t *testing.T) {
+	b := mustEncode(t, &Marketer{Organize: OkEx, Symbol: "BTC-USDT", BuyDepth: Depth{{"1", "2"}}})
+
+	for _, v := range [][]byte{nil, {2}, b[:len(b)-1], b[:5]} {
+		if _, err := DecodeMarketer(v); err == nil {
+			t.Fatal(v)
+		}
+	}
+}
+
+func TestParseFixed(t *testing.T) {
+	for s, want := range map[string][2]int64{
+		"9000":     {9000, 0},
+		"9000.10":  {90001, 1},
+		"-0.5":     {-5, 1},
+		"1e-05":    {1, 5},
+		"1.5E3":    {1500, 0},
+		"0.000":    {0, 0},
+		"120.5000": {1205, 1},
+	} {
+		n, scale, err := parseFixed(s)
+		if err != nil || n != want[0] || int64(scale) != want[1] {
+			t.Fatal(s, n, scale, err)
+		}
+		if got := formatFixed(n, scale); got != formatFixed(want[0], uint8(want[1])) {
+			t.Fatal(s, got)
+		}
+	}
+	if formatFixed(-5, 3) != "-0.005" || formatFixed(1200, 2) != "12" || formatFixed(1205, 2) != "12.05" {
+		t.Fatal(formatFixed(-5, 3), formatFixed(1200, 2), formatFixed(1205, 2))
+	}
+}
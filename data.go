@@ -25,14 +25,43 @@ func (d Depth) formatFloat(params [][2]float64) Depth {
 
 //基础行情结构
 type Marketer struct {
-	Organize  Organize      `json:"organize"`             //交易所
-	Symbol    string        `json:"symbol"`               //订阅币对
-	BuyFirst  string        `json:"buy_first,omitempty"`  //买一价格
-	SellFirst string        `json:"sell_first,omitempty"` //卖一价格
-	BuyDepth  Depth         `json:"buy_depth,omitempty"`  //市场买深度
-	SellDepth Depth         `json:"sell_depth,omitempty"` //市场卖深度
-	Timestamp time.Duration `json:"timestamp,omitempty"`  //数据更新时间(毫秒)
-	Temporize time.Duration `json:"temporize,omitempty"`  //网络延迟(毫秒)
+	Organize      Organize      `json:"organize"`                  //交易所
+	Symbol        string        `json:"symbol"`                    //订阅币对
+	BuyFirst      string        `json:"buy_first,omitempty"`       //买一价格
+	BuyFirstSize  string        `json:"buy_first_size,omitempty"`  //买一数量
+	SellFirst     string        `json:"sell_first,omitempty"`      //卖一价格
+	SellFirstSize string        `json:"sell_first_size,omitempty"` //卖一数量
+	BuyDepth      Depth         `json:"buy_depth,omitempty"`       //市场买深度
+	SellDepth     Depth         `json:"sell_depth,omitempty"`      //市场卖深度
+	Timestamp     time.Time     `json:"timestamp"`                 //交易所数据更新时间
+	Temporize     time.Duration `json:"temporize,omitempty"`       //网络延迟, 本地接收时间减去交易所时间, 包含时钟偏移
+	Latency       time.Duration `json:"latency,omitempty"`         //校正交易所时钟偏移后的网络延迟
+	ReceivedAt    time.Time     `json:"received_at"`               //本地接收时间
+	Seq           uint64        `json:"seq,omitempty"`             //本地序号, 每个币对单独递增
+	ExchangeSeq   uint64        `json:"exchange_seq,omitempty"`    //交易所序号, 交易所不提供时为0
+	topic         string        //订阅主题, 用于查找订阅的深度档位
+}
+
+//按订阅档位截取深度
+//档位小于等于0时不截取
+func (m *Marketer) trimDepth(level DepthLevel) {
+	if level <= 0 {
+		return
+	}
+	if len(m.BuyDepth) > int(level) {
+		m.BuyDepth = m.BuyDepth[:level]
+	}
+	if len(m.SellDepth) > int(level) {
+		m.SellDepth = m.SellDepth[:level]
+	}
+}
+
+//记录本地接收时间, 计算网络延迟
+//Latency使用接收时的交易所时钟偏移offset校正, 还没有同步时和Temporize相同
+func (m *Marketer) stamp(received time.Time, offset time.Duration) {
+	m.ReceivedAt = received
+	m.Temporize = received.Sub(m.Timestamp)
+	m.Latency = m.Temporize + offset
 }
 
 //序列化为json
@@ -41,6 +70,49 @@ func (m *Marketer) MarshalJson() []byte {
 	return j
 }
 
+//json时间格式
+//true时时间和延迟序列化为毫秒整数, 兼容之前的格式
+//false时时间序列化为RFC3339, 延迟序列化为纳秒整数
+var JsonMillisecond = true
+
+//时间转换为毫秒时间戳, 零值返回0
+func millisecond(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+//毫秒时间戳转换为时间, 0返回零值
+func fromMillisecond(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+//按JsonMillisecond序列化
+func (m *Marketer) MarshalJSON() ([]byte, error) {
+	type alias Marketer
+	if !JsonMillisecond {
+		return json.Marshal((*alias)(m))
+	}
+
+	return json.Marshal(&struct {
+		*alias
+		Timestamp  int64 `json:"timestamp,omitempty"`
+		Temporize  int64 `json:"temporize,omitempty"`
+		Latency    int64 `json:"latency,omitempty"`
+		ReceivedAt int64 `json:"received_at,omitempty"`
+	}{
+		alias:      (*alias)(m),
+		Timestamp:  millisecond(m.Timestamp),
+		Temporize:  m.Temporize.Milliseconds(),
+		Latency:    m.Latency.Milliseconds(),
+		ReceivedAt: millisecond(m.ReceivedAt),
+	})
+}
+
 //基础的lister类型
 //主要为了实现主动查询
 type Lister struct {
@@ -95,20 +167,31 @@ func (l *Lister) Find(s ...string) *Lister {
 	return newL
 }
 
+//返回所有数据
+func (l *Lister) values() []*Marketer {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	values := make([]*Marketer, 0, len(l.data))
+	for _, v := range l.data {
+		values = append(values, v)
+	}
+	return values
+}
+
 func (l *Lister) ToMap() map[string]*Marketer {
 	return l.data
 }
 
 //lister gc机制
-//exs单位是秒. 表示数据过期的时间
+//now为当前时间, 回放时使用回放时钟
+//exs表示数据过期的时间
 //数据过期后删除
-func (l *Lister) gc(exs time.Duration) {
-	t := time.Duration(time.Now().UnixNano() / 1e6)
-	exs = exs / time.Millisecond
+func (l *Lister) gc(now time.Time, exs time.Duration) {
 	l.lock.Lock()
 	defer l.lock.Unlock()
 	for k, v := range l.data {
-		if (t - v.Timestamp) > exs {
+		if now.Sub(v.Timestamp) > exs {
 			delete(l.data, k)
 		}
 	}
@@ -138,11 +221,74 @@ const HuoBi Organize = "huobi"
 //okex平台常量
 const OkEx Organize = "okex"
 
+//订阅数据类型
+type DataType int
+
+//深度数据类型, 默认类型
+const DepthData DataType = 0
+
+//资金费率数据类型(永续)
+const FundingRateData DataType = 1
+
+//标记价格数据类型(永续)
+const MarkPriceData DataType = 2
+
+//指数价格数据类型
+const IndexPriceData DataType = 3
+
+//持仓量数据类型(交割/永续)
+const OpenInterestData DataType = 4
+
+//强平订单数据类型(交割/永续)
+const LiquidationData DataType = 5
+
+//最优买卖价数据类型
+//只推送买一卖一, 不解析深度
+const BBOData DataType = 6
+
+//深度档位
+type DepthLevel int
+
+//交易所默认档位, okex为5档, 火币为20档
+const DefaultDepthLevel DepthLevel = 0
+
+//5档深度
+const Depth5 DepthLevel = 5
+
+//20档深度
+const Depth20 DepthLevel = 20
+
+//150档深度
+const Depth150 DepthLevel = 150
+
+//全量深度
+//okex使用增量深度本地合并, 火币没有全量深度推送, 使用step0最多150档
+const FullDepth DepthLevel = -1
+
+//深度合并精度(火币)
+//step0不合并, step1-step5合并精度依次降低
+type DepthStep string
+
+//交易所默认精度, 150档和全量深度为step0, 其他为step1
+const DefaultDepthStep DepthStep = ""
+
+const Step0 DepthStep = "step0"
+const Step1 DepthStep = "step1"
+const Step2 DepthStep = "step2"
+const Step3 DepthStep = "step3"
+const Step4 DepthStep = "step4"
+const Step5 DepthStep = "step5"
+
 //外部订阅时的结构体
 type Subscriber struct {
 	Symbol     string
 	Organize   Organize
 	MarketType MarketType
+	DataType   DataType
+	DepthLevel DepthLevel    //深度档位, 只对深度数据有效
+	DepthStep  DepthStep     //深度合并精度, 只对火币深度数据有效
+	Redundant  bool          //热备订阅, 同时使用两个连接订阅, 推送先到达的数据
+	StaleAfter time.Duration //超过这个时间没有推送则重新订阅, 0使用StaleThreshold, 小于0不检测
 }
 
 //只允许写入Subscriber channel
@@ -153,18 +299,33 @@ var WriteSubscribing chan<- *Subscriber
 //不允许外部使用
 var readSubscribing <-chan *Subscriber
 
+//只允许写入取消订阅的channel
+//暴露给外部使用, 使用和订阅时相同的Subscriber
+//订阅按主题引用计数, 订阅几次需要取消几次
+var WriteUnsubscribing chan<- *Subscriber
+
+//只允许读取取消订阅的channel
+//不允许外部使用
+var readUnsubscribing <-chan *Subscriber
+
 func init() {
 	var subscribing = make(chan *Subscriber, 2)
 	WriteSubscribing = subscribing
 	readSubscribing = subscribing
+
+	var unsubscribing = make(chan *Subscriber, 2)
+	WriteUnsubscribing = unsubscribing
+	readUnsubscribing = unsubscribing
 }
 
 //只允许读取market channel
 type readMarketer <-chan *Marketer
 
 //只允许写入market channel
+//超过缓存删除的数据推送断档事件到gaps
 type writeMarketer struct {
-	buffer chan<- *Marketer
+	buffer chan *Marketer
+	gaps   *output
 	lock   sync.Mutex
 }
 
@@ -180,22 +341,27 @@ var writeMarketPool struct {
 
 func init() {
 	writeMarketPool.buffer = readWriteMarketer
+	writeMarketPool.gaps = liveOutput
 }
 
 //使用channel对market实现环形数据结构
-//超过channel缓存时, 删除过期的值
-//主动停止timer, 防止可能的内存泄露
-func (w writeMarketer) writeRingBuffer(m *Marketer) {
+//超过channel缓存时, 删除过期的值, 并推送断档事件, LastSeq为删除数据的前一个序号
+//断档事件在释放lock后输出
+func (w *writeMarketer) writeRingBuffer(m *Marketer) {
+	var gap *Gap
 	w.lock.Lock()
-	defer func() {
-		w.lock.Unlock()
-	}()
-
 	if len(w.buffer) == cap(w.buffer) {
 		select {
-		case <-ReadMarketPool:
+		case old := <-w.buffer:
+			metrics().drop("market")
+			gap = newGap(old.Base(), GapRingBuffer, old.Seq-1)
 		default:
 		}
 	}
 	w.buffer <- m
+	w.lock.Unlock()
+
+	if gap != nil {
+		w.gaps.event(gap)
+	}
 }
@@ -1,7 +1,9 @@
 package market
 
 import (
+	"context"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 )
@@ -11,6 +13,27 @@ func TestMarketer_MarshalJson(t *testing.T) {
 	fmt.Println(m.MarshalJson())
 }
 
+func TestMarketer_MarshalJSON(t *testing.T) {
+	m := &Marketer{
+		Symbol:    "BTC-USDT",
+		Timestamp: time.Unix(1420674445, 201e6),
+		Temporize: 35 * time.Millisecond,
+	}
+
+	if j := string(m.MarshalJson()); !strings.Contains(j, `"timestamp":1420674445201`) || !strings.Contains(j, `"temporize":35}`) || strings.Contains(j, "received_at") {
+		t.Fatal(j)
+	}
+
+	JsonMillisecond = false
+	defer func() {
+		JsonMillisecond = true
+	}()
+
+	if j := string(m.MarshalJson()); !strings.Contains(j, `"timestamp":"2015-01-07T`) || !strings.Contains(j, `"temporize":35000000`) {
+		t.Fatal(j)
+	}
+}
+
 func TestLister_Del(t *testing.T) {
 	l := newList()
 	l.Add("btc", NewTestMarketer())
@@ -44,11 +67,14 @@ func NewTestMarketer() *Marketer {
 		SellFirst: "213123",
 		BuyDepth:  d,
 		SellDepth: d,
-		Timestamp: time.Duration(time.Now().UnixNano() / 1e6),
+		Timestamp: time.Now(),
 	}
 }
 
 func Test_WriteSubscribing(t *testing.T) {
+	m := newMockExchange(t, OkEx)
+	g := newMockGroup(t, m, newOkEx)
+
 	s := &Subscriber{
 		Symbol:     "ETH-USDT",
 		MarketType: SpotMarket,
@@ -56,10 +82,9 @@ func Test_WriteSubscribing(t *testing.T) {
 	}
 	WriteSubscribing <- s
 
-	select {
-	case sub := <-readSubscribing:
-		Manage.Tasks[sub.Organize].subscribeHandle(sub)
-	}
+	sub := waitSubscribing(t)
+	routeOf(map[Organize]*workerGroup{OkEx: g}, sub).subscribeHandle(sub)
+	m.waitSub(t, "spot/depth5:ETH-USDT")
 }
 
 func Test_WriteRingBuffer(t *testing.T) {
@@ -71,3 +96,63 @@ func Test_WriteRingBuffer(t *testing.T) {
 
 	fmt.Println(<-ReadMarketPool)
 }
+
+func Test_WriteRingBufferOverflow(t *testing.T) {
+	sink := &memorySink{}
+	pub := NewPublisher(sink, SinkOptions{FlushInterval: 5 * time.Millisecond})
+	SetPublisher(pub)
+	defer SetPublisher(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go pub.Run(ctx)
+
+	markets := make(chan *Marketer, 2)
+	gaps := make(chan Eventer, 2)
+	w := &writeMarketer{buffer: markets, gaps: &output{events: &writeEventer{buffer: gaps}, live: true}}
+
+	for i := 1; i <= 3; i++ {
+		w.writeRingBuffer(&Marketer{Organize: OkEx, Symbol: "BTC-USDT", Seq: uint64(i)})
+	}
+
+	//删除最旧的序号1, 之前推送的最后序号为0
+	if m := <-markets; m.Seq != 2 {
+		t.Fatal(m)
+	}
+	gap := (<-gaps).(*Gap)
+	if gap.Reason != GapRingBuffer || gap.Stream != DepthEvent || gap.Symbol != "BTC-USDT" || gap.LastSeq != 0 {
+		t.Fatal(gap)
+	}
+
+	//断档事件同样推送到下游
+	for deadline := time.Now().Add(time.Second); sink.count() < 1 && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+	}
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	if len(sink.batches) != 1 || sink.batches[0][0].Type != GapEvent {
+		t.Fatal(sink.batches)
+	}
+}
+
+func Test_WriteEventRingBuffer(t *testing.T) {
+	e := &FundingRate{
+		Event: Event{
+			Type:      FundingRateEvent,
+			Organize:  OkEx,
+			Symbol:    "BTC-USD-SWAP",
+			Timestamp: time.Now(),
+		},
+		FundingRate:   "0.0001",
+		EstimatedRate: "0.0002",
+	}
+
+	go func() {
+		writeEventPool.writeRingBuffer(e)
+	}()
+
+	got := waitEvent(t, func(v Eventer) bool { return v.Base().Symbol == "BTC-USD-SWAP" })
+	f, ok := got.(*FundingRate)
+	if !ok || f.Type != FundingRateEvent || f.Organize != OkEx || f.FundingRate != "0.0001" || f.EstimatedRate != "0.0002" {
+		t.Fatal(got)
+	}
+}
@@ -0,0 +1,268 @@
+package market
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+//事件类型
+type EventType string
+
+//深度行情事件
+const DepthEvent EventType = "depth"
+
+//资金费率事件
+const FundingRateEvent EventType = "funding_rate"
+
+//标记价格事件
+const MarkPriceEvent EventType = "mark_price"
+
+//指数价格事件
+const IndexPriceEvent EventType = "index_price"
+
+//持仓量事件
+const OpenInterestEvent EventType = "open_interest"
+
+//强平订单事件
+const LiquidationEvent EventType = "liquidation"
+
+//最优买卖价事件
+const BBOEvent EventType = "bbo"
+
+//数据断档事件
+const GapEvent EventType = "gap"
+
+//ws重连事件
+const ReconnectEvent EventType = "reconnect"
+
+//数据流停止推送事件
+const StaleEvent EventType = "stale"
+
+//订阅数据类型对应的事件类型
+func (d DataType) eventType() EventType {
+	switch d {
+	case FundingRateData:
+		return FundingRateEvent
+	case MarkPriceData:
+		return MarkPriceEvent
+	case IndexPriceData:
+		return IndexPriceEvent
+	case OpenInterestData:
+		return OpenInterestEvent
+	case LiquidationData:
+		return LiquidationEvent
+	case BBOData:
+		return BBOEvent
+	}
+
+	return DepthEvent
+}
+
+//事件基础结构
+//所有推送事件都包含该结构
+type Event struct {
+	Type        EventType `json:"type"`                   //事件类型
+	Organize    Organize  `json:"organize"`               //交易所
+	Symbol      string    `json:"symbol"`                 //合约或者币对
+	Timestamp   time.Time `json:"timestamp"`              //交易所数据更新时间, 本地事件为本地时间
+	Seq         uint64    `json:"seq,omitempty"`          //本地序号, 每个数据类型和币对单独递增
+	ExchangeSeq uint64    `json:"exchange_seq,omitempty"` //交易所序号, 交易所不提供时为0
+}
+
+//返回事件基础结构
+func (e *Event) Base() *Event {
+	return e
+}
+
+//事件接口
+//handler格式化后的数据都实现该接口
+type Eventer interface {
+	Base() *Event
+}
+
+//深度行情实现事件接口
+func (m *Marketer) Base() *Event {
+	return &Event{
+		Type:        DepthEvent,
+		Organize:    m.Organize,
+		Symbol:      m.Symbol,
+		Timestamp:   m.Timestamp,
+		Seq:         m.Seq,
+		ExchangeSeq: m.ExchangeSeq,
+	}
+}
+
+//资金费率
+type FundingRate struct {
+	Event
+	FundingRate     string    `json:"funding_rate"`      //当期资金费率
+	EstimatedRate   string    `json:"estimated_rate"`    //预测资金费率
+	NextFundingTime time.Time `json:"next_funding_time"` //下次结算时间
+}
+
+//按JsonMillisecond序列化
+func (e *FundingRate) MarshalJSON() ([]byte, error) {
+	type alias FundingRate
+	if !JsonMillisecond {
+		return json.Marshal((*alias)(e))
+	}
+
+	return json.Marshal(&struct {
+		*alias
+		Timestamp       int64 `json:"timestamp,omitempty"`
+		NextFundingTime int64 `json:"next_funding_time"`
+	}{
+		alias:           (*alias)(e),
+		Timestamp:       millisecond(e.Timestamp),
+		NextFundingTime: millisecond(e.NextFundingTime),
+	})
+}
+
+//标记价格
+type MarkPrice struct {
+	Event
+	MarkPrice string `json:"mark_price"` //标记价格
+}
+
+//按JsonMillisecond序列化
+func (e *MarkPrice) MarshalJSON() ([]byte, error) {
+	type alias MarkPrice
+	if !JsonMillisecond {
+		return json.Marshal((*alias)(e))
+	}
+
+	return json.Marshal(&struct {
+		*alias
+		Timestamp int64 `json:"timestamp,omitempty"`
+	}{alias: (*alias)(e), Timestamp: millisecond(e.Timestamp)})
+}
+
+//指数价格
+type IndexPrice struct {
+	Event
+	IndexPrice string `json:"index_price"` //指数价格
+}
+
+//按JsonMillisecond序列化
+func (e *IndexPrice) MarshalJSON() ([]byte, error) {
+	type alias IndexPrice
+	if !JsonMillisecond {
+		return json.Marshal((*alias)(e))
+	}
+
+	return json.Marshal(&struct {
+		*alias
+		Timestamp int64 `json:"timestamp,omitempty"`
+	}{alias: (*alias)(e), Timestamp: millisecond(e.Timestamp)})
+}
+
+//持仓量
+type OpenInterest struct {
+	Event
+	Volume string `json:"volume"`           //持仓量(张)
+	Amount string `json:"amount,omitempty"` //持仓量(币), 交易所不提供时为空
+}
+
+//按JsonMillisecond序列化
+func (e *OpenInterest) MarshalJSON() ([]byte, error) {
+	type alias OpenInterest
+	if !JsonMillisecond {
+		return json.Marshal((*alias)(e))
+	}
+
+	return json.Marshal(&struct {
+		*alias
+		Timestamp int64 `json:"timestamp,omitempty"`
+	}{alias: (*alias)(e), Timestamp: millisecond(e.Timestamp)})
+}
+
+//强平订单
+type Liquidation struct {
+	Event
+	Side  string `json:"side"`  //强平订单方向 buy/sell
+	Price string `json:"price"` //强平价格
+	Size  string `json:"size"`  //强平数量(张)
+}
+
+//按JsonMillisecond序列化
+func (e *Liquidation) MarshalJSON() ([]byte, error) {
+	type alias Liquidation
+	if !JsonMillisecond {
+		return json.Marshal((*alias)(e))
+	}
+
+	return json.Marshal(&struct {
+		*alias
+		Timestamp int64 `json:"timestamp,omitempty"`
+	}{alias: (*alias)(e), Timestamp: millisecond(e.Timestamp)})
+}
+
+//最优买卖价
+//只包含买一卖一, 不包含深度
+type BBO struct {
+	Event
+	BidPrice string `json:"bid_price"` //买一价格
+	BidSize  string `json:"bid_size"`  //买一数量
+	AskPrice string `json:"ask_price"` //卖一价格
+	AskSize  string `json:"ask_size"`  //卖一数量
+}
+
+//按JsonMillisecond序列化
+func (e *BBO) MarshalJSON() ([]byte, error) {
+	type alias BBO
+	if !JsonMillisecond {
+		return json.Marshal((*alias)(e))
+	}
+
+	return json.Marshal(&struct {
+		*alias
+		Timestamp int64 `json:"timestamp,omitempty"`
+	}{alias: (*alias)(e), Timestamp: millisecond(e.Timestamp)})
+}
+
+//一次推送包含的多个事件
+//写入event pool时拆开
+type eventBatch []Eventer
+
+//返回第一个事件的基础结构
+func (b eventBatch) Base() *Event {
+	return b[0].Base()
+}
+
+//只允许读取event channel
+type readEventer <-chan Eventer
+
+//只允许写入event channel
+type writeEventer struct {
+	buffer chan Eventer
+	lock   sync.Mutex
+}
+
+var readWriteEventer = make(chan Eventer, 1000)
+
+//读取暴露给外部使用
+//深度行情以外的事件都从这里读取
+var ReadEventPool readEventer = readWriteEventer
+
+//写入数据只能内部使用
+var writeEventPool = &writeEventer{
+	buffer: readWriteEventer,
+}
+
+//使用channel对event实现环形数据结构
+//超过channel缓存时, 删除最旧的值
+//删除事件不推送断档事件, 消费者通过本地序号判断
+func (w *writeEventer) writeRingBuffer(e Eventer) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if len(w.buffer) == cap(w.buffer) {
+		select {
+		case <-w.buffer:
+			metrics().drop("event")
+		default:
+		}
+	}
+	w.buffer <- e
+}
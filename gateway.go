@@ -0,0 +1,326 @@
+package market
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+//每个下游连接等待发送的数据数量
+//超过后视为慢连接, 断开该连接
+const gatewayBuffer = 256
+
+//下游连接写入超时时间
+const gatewayWriteTimeout = 10 * time.Second
+
+//下游订阅的数据流
+//转发的数据按交易所, 币对和事件类型匹配
+type gatewayStream struct {
+	organize Organize
+	symbol   string
+	stream   EventType
+}
+
+//下游订阅
+//包含决定交易所订阅主题的所有字段, 档位或者市场类型不同是不同的订阅
+type gatewayKey struct {
+	gatewayStream
+	marketType MarketType
+	depthLevel DepthLevel
+	depthStep  DepthStep
+}
+
+//返回订阅对应的key
+func newGatewayKey(s *Subscriber) gatewayKey {
+	return gatewayKey{
+		gatewayStream: gatewayStream{organize: s.Organize, symbol: s.Symbol, stream: s.DataType.eventType()},
+		marketType:    s.MarketType,
+		depthLevel:    s.DepthLevel,
+		depthStep:     s.DepthStep,
+	}
+}
+
+//下游订阅请求
+//op为subscribe或者unsubscribe, 其他字段和http新增订阅相同
+type gatewayRequest struct {
+	Op string `json:"op"`
+	subscribeRequest
+}
+
+//下游订阅返回
+type gatewayReply struct {
+	Event    string    `json:"event"` //subscribe, unsubscribe或者error
+	Organize Organize  `json:"organize,omitempty"`
+	Symbol   string    `json:"symbol,omitempty"`
+	Stream   EventType `json:"stream,omitempty"`
+	Message  string    `json:"message,omitempty"` //错误信息
+}
+
+//下游连接
+type gatewayClient struct {
+	conn    *websocket.Conn
+	send    chan []byte
+	subs    map[gatewayKey]*Subscriber //向交易所发送的订阅, 使用Gateway.lock
+	streams map[gatewayStream]int      //每个数据流的订阅数量, 使用Gateway.lock
+	once    sync.Once
+}
+
+//关闭下游连接
+//读取协程退出后清理订阅
+func (c *gatewayClient) close() {
+	c.once.Do(func() {
+		close(c.send)
+	})
+}
+
+//ws转发服务
+//下游连接发送订阅和取消订阅, 接收json格式的行情和事件
+//每个下游订阅发送一次WriteSubscribing, 取消时发送一次WriteUnsubscribing
+//交易所订阅按主题引用计数, 下游全部取消后不影响其他使用WriteSubscribing的订阅
+type Gateway struct {
+	upgrader websocket.Upgrader
+	clients  map[*gatewayClient]bool
+	lock     sync.RWMutex
+}
+
+//创建ws转发服务
+//使用SetGateway后开始转发数据, 作为http.Handler挂载到调用方的http服务
+func NewGateway() *Gateway {
+	return &Gateway{
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+		clients: make(map[*gatewayClient]bool),
+	}
+}
+
+//设置ws转发服务
+//nil停止转发
+func SetGateway(g *Gateway) {
+	Manage.gateway.Store(g)
+}
+
+//当前使用的ws转发服务
+func gateway() *Gateway {
+	g, _ := Manage.gateway.Load().(*Gateway)
+	return g
+}
+
+//处理下游ws连接
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := g.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	c := &gatewayClient{
+		conn:    conn,
+		send:    make(chan []byte, gatewayBuffer),
+		subs:    make(map[gatewayKey]*Subscriber),
+		streams: make(map[gatewayStream]int),
+	}
+	g.lock.Lock()
+	g.clients[c] = true
+	g.lock.Unlock()
+
+	go g.writeHandle(c)
+	g.readHandle(c)
+}
+
+//读取下游的订阅请求
+//连接断开后取消该连接的所有订阅
+func (g *Gateway) readHandle(c *gatewayClient) {
+	defer func() {
+		g.remove(c)
+		c.close()
+		c.conn.Close()
+	}()
+
+	for {
+		_, msg, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		req := &gatewayRequest{}
+		if err := json.Unmarshal(msg, req); err != nil {
+			g.reply(c, &gatewayReply{Event: "error", Message: err.Error()})
+			continue
+		}
+		if req.Organize == "" || req.Symbol == "" {
+			g.reply(c, &gatewayReply{Event: "error", Message: "缺少organize或者symbol"})
+			continue
+		}
+
+		s := req.subscriber()
+		key := newGatewayKey(s)
+		switch req.Op {
+		case "subscribe":
+			g.subscribe(c, key, s)
+		case "unsubscribe":
+			g.unsubscribe(c, key)
+		default:
+			g.reply(c, &gatewayReply{Event: "error", Message: "不支持的op " + req.Op})
+			continue
+		}
+		g.reply(c, &gatewayReply{Event: req.Op, Organize: key.organize, Symbol: key.symbol, Stream: key.stream})
+	}
+}
+
+//发送数据到下游
+//发送失败或者连接关闭后退出
+func (g *Gateway) writeHandle(c *gatewayClient) {
+	defer c.conn.Close()
+
+	for msg := range c.send {
+		c.conn.SetWriteDeadline(time.Now().Add(gatewayWriteTimeout))
+		if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+			return
+		}
+	}
+	c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+}
+
+//下游订阅一个数据流
+//同一个连接重复订阅时忽略
+func (g *Gateway) subscribe(c *gatewayClient, key gatewayKey, s *Subscriber) {
+	g.lock.Lock()
+	if _, ok := c.subs[key]; ok {
+		g.lock.Unlock()
+		return
+	}
+	c.subs[key] = s
+	c.streams[key.gatewayStream]++
+	g.lock.Unlock()
+
+	WriteSubscribing <- s
+}
+
+//下游取消订阅一个数据流
+func (g *Gateway) unsubscribe(c *gatewayClient, key gatewayKey) {
+	g.lock.Lock()
+	s := c.release(key)
+	g.lock.Unlock()
+
+	if s != nil {
+		WriteUnsubscribing <- s
+	}
+}
+
+//删除下游连接
+//取消该连接的所有订阅
+func (g *Gateway) remove(c *gatewayClient) {
+	var unsubs []*Subscriber
+	g.lock.Lock()
+	delete(g.clients, c)
+	for key := range c.subs {
+		unsubs = append(unsubs, c.release(key))
+	}
+	g.lock.Unlock()
+
+	for _, s := range unsubs {
+		WriteUnsubscribing <- s
+	}
+}
+
+//删除连接的一个订阅
+//返回需要取消的交易所订阅, 没有订阅时返回nil
+//调用方需要持有Gateway.lock
+func (c *gatewayClient) release(key gatewayKey) *Subscriber {
+	s, ok := c.subs[key]
+	if !ok {
+		return nil
+	}
+
+	delete(c.subs, key)
+	c.streams[key.gatewayStream]--
+	if c.streams[key.gatewayStream] <= 0 {
+		delete(c.streams, key.gatewayStream)
+	}
+	return s
+}
+
+//返回订阅结果
+func (g *Gateway) reply(c *gatewayClient, r *gatewayReply) {
+	msg, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	g.send(c, msg)
+}
+
+//写入下游连接的发送队列
+//队列已满时断开该连接
+//调用方需要持有lock或者RLock
+func (g *Gateway) send(c *gatewayClient, msg []byte) {
+	if !g.clients[c] {
+		return
+	}
+
+	select {
+	case c.send <- msg:
+	default:
+		logger().Warn("下游连接发送队列已满, 断开连接", "remote", c.conn.RemoteAddr().String())
+		c.conn.Close()
+	}
+}
+
+//转发一个事件到订阅的下游连接
+func (g *Gateway) publish(data Eventer) {
+	if g == nil {
+		return
+	}
+
+	b := data.Base()
+	key := gatewayStream{organize: b.Organize, symbol: b.Symbol, stream: b.Type}
+
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	var msg []byte
+	for c := range g.clients {
+		if c.streams[key] == 0 {
+			continue
+		}
+
+		if msg == nil {
+			var err error
+			if msg, err = json.Marshal(data); err != nil {
+				logger().Warn("序列化转发数据失败", "organize", b.Organize, "symbol", b.Symbol, "err", err)
+				return
+			}
+		}
+		g.send(c, msg)
+	}
+}
+
+//断开所有下游连接
+//下游连接的订阅随连接断开取消
+func (g *Gateway) Close() {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	for c := range g.clients {
+		c.conn.Close()
+	}
+}
+
+//转换为订阅
+func (r *subscribeRequest) subscriber() *Subscriber {
+	return &Subscriber{
+		Symbol:     r.Symbol,
+		Organize:   r.Organize,
+		MarketType: r.MarketType,
+		DataType:   r.DataType,
+		DepthLevel: r.DepthLevel,
+		DepthStep:  r.DepthStep,
+		Redundant:  r.Redundant,
+		StaleAfter: time.Duration(r.StaleAfter) * time.Millisecond,
+	}
+}
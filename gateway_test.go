@@ -0,0 +1,137 @@
+package market
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+//连接ws转发服务并订阅
+func dialGateway(t *testing.T, url string, req string) *websocket.Conn {
+	t.Helper()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(url, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(req)); err != nil {
+		t.Fatal(err)
+	}
+
+	reply := &gatewayReply{}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if err := conn.ReadJSON(reply); err != nil || reply.Event != "subscribe" {
+		t.Fatal(reply, err)
+	}
+	return conn
+}
+
+func TestGateway_Publish(t *testing.T) {
+	g := NewGateway()
+	server := httptest.NewServer(g)
+	defer server.Close()
+	SetGateway(g)
+	defer SetGateway(nil)
+
+	req := `{"op":"subscribe","organize":"okex","symbol":"GW-USDT","market_type":1}`
+	c1 := dialGateway(t, server.URL, req)
+	defer c1.Close()
+	select {
+	case s := <-readSubscribing:
+		if s.Organize != OkEx || s.Symbol != "GW-USDT" || s.MarketType != SpotMarket {
+			t.Fatal(s)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("没有收到上游订阅")
+	}
+
+	//每个下游订阅都发送一次, 由订阅处理按主题引用计数
+	c2 := dialGateway(t, server.URL, req)
+	defer c2.Close()
+	select {
+	case <-readSubscribing:
+	case <-time.After(time.Second):
+		t.Fatal("没有收到上游订阅")
+	}
+
+	//档位不同是不同的订阅
+	c2.WriteMessage(websocket.TextMessage, []byte(`{"op":"subscribe","organize":"okex","symbol":"GW-USDT","market_type":1,"depth_level":2}`))
+	select {
+	case s := <-readSubscribing:
+		if s.DepthLevel != DepthLevel(2) {
+			t.Fatal(s)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("没有收到上游订阅")
+	}
+
+	publish(&Marketer{Organize: OkEx, Symbol: "OTHER-USDT", BuyFirst: "1"})
+	publish(&Marketer{Organize: OkEx, Symbol: "GW-USDT", BuyFirst: "9000"})
+	for _, c := range []*websocket.Conn{c1, c2} {
+		var m map[string]interface{}
+		c.SetReadDeadline(time.Now().Add(time.Second))
+		for {
+			m = map[string]interface{}{}
+			if _, msg, err := c.ReadMessage(); err != nil || json.Unmarshal(msg, &m) != nil {
+				t.Fatal(m, err)
+			}
+			//跳过第二个订阅的返回
+			if m["event"] == nil {
+				break
+			}
+		}
+		if m["symbol"] != "GW-USDT" || m["buy_first"] != "9000" {
+			t.Fatal(m)
+		}
+	}
+
+	//下游取消时取消该下游的上游订阅
+	c1.WriteMessage(websocket.TextMessage, []byte(`{"op":"unsubscribe","organize":"okex","symbol":"GW-USDT","market_type":1}`))
+	select {
+	case s := <-readUnsubscribing:
+		if s.Organize != OkEx || s.Symbol != "GW-USDT" || s.DepthLevel != 0 {
+			t.Fatal(s)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("没有收到上游取消订阅")
+	}
+
+	//下游断开后取消该下游的所有订阅
+	c2.Close()
+	levels := map[DepthLevel]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case s := <-readUnsubscribing:
+			levels[s.DepthLevel] = true
+		case <-time.After(time.Second):
+			t.Fatal("没有收到上游取消订阅")
+		}
+	}
+	if !levels[0] || !levels[2] {
+		t.Fatal(levels)
+	}
+}
+
+func TestGateway_BadRequest(t *testing.T) {
+	server := httptest.NewServer(NewGateway())
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for _, req := range []string{`xxx`, `{"op":"subscribe","symbol":"GW-USDT"}`, `{"op":"xxx","organize":"okex","symbol":"GW-USDT"}`} {
+		conn.WriteMessage(websocket.TextMessage, []byte(req))
+		reply := &gatewayReply{}
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		if err := conn.ReadJSON(reply); err != nil || reply.Event != "error" || reply.Message == "" {
+			t.Fatal(req, reply, err)
+		}
+	}
+}
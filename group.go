@@ -0,0 +1,283 @@
+package market
+
+import (
+	"context"
+	"runtime/debug"
+	"sort"
+	"sync"
+)
+
+//每个ws连接最多订阅的主题数
+//超过后创建新的连接, 0表示不限制
+var MaxTopicsPerConn = 100
+
+//同一个ws地址的worker集合
+//订阅按主题分配到多个连接, 共享行情数据list和序号
+type workerGroup struct {
+	ctx         context.Context
+	Organize    Organize
+	List        *Lister                       //所有连接共享的行情数据list
+	seqs        *sequences                    //所有连接共享的数据流序号
+	out         *output                       //处理后数据的输出目标
+	newWorker   func(context.Context) *Worker //创建一个新的连接
+	wsUrl       string                        //替换连接的ws地址, 为空时使用交易所地址
+	workers     []*Worker
+	depthLevels map[string]DepthLevel  //订阅的深度档位, key为订阅主题
+	subscribers map[string]*Subscriber //订阅主题对应的订阅, key为订阅主题
+	watches     map[string]*watch      //需要检测停止推送的主题, key为订阅主题
+	refs        map[string]int         //每个主题的订阅次数, 使用lock
+	levelLock   sync.RWMutex           //保护depthLevels和subscribers
+	running     bool
+	lock        sync.Mutex
+}
+
+//创建worker集合
+//默认创建一个连接
+func newWorkerGroup(ctx context.Context, newWorker func(context.Context) *Worker) *workerGroup {
+	g := &workerGroup{
+		ctx:         ctx,
+		List:        newList(),
+		seqs:        newSequences(),
+		out:         liveOutput,
+		newWorker:   newWorker,
+		depthLevels: make(map[string]DepthLevel),
+		subscribers: make(map[string]*Subscriber),
+		watches:     make(map[string]*watch),
+		refs:        make(map[string]int),
+	}
+
+	w := g.add()
+	g.Organize = w.Organize
+	return g
+}
+
+//创建一个连接加入集合
+//集合运行中时直接运行该连接
+//调用方需要持有lock, 或者集合还没有被并发使用
+func (g *workerGroup) add() *Worker {
+	w := g.newWorker(g.ctx)
+	w.group = g
+	w.id = len(g.workers)
+	w.List = g.List
+	w.seqs = g.seqs
+	if g.wsUrl != "" {
+		w.wsUrl = g.wsUrl
+	}
+	g.workers = append(g.workers, w)
+
+	if g.running {
+		go runWorker(w)
+	}
+	return w
+}
+
+//替换集合中所有连接的ws地址
+//之后创建的连接也使用该地址
+func (g *workerGroup) setWsUrl(url string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.wsUrl = url
+	for _, w := range g.workers {
+		w.wsUrl = url
+	}
+}
+
+//运行集合中的所有连接
+//创建停止推送检测协程
+func (g *workerGroup) RunTask() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.running = true
+	for _, w := range g.workers {
+		go runWorker(w)
+	}
+	go g.watchdog()
+}
+
+func runWorker(w *Worker) {
+	defer func() {
+		if err := recover(); err != nil {
+			logger().Error("运行异常", "organize", w.Organize, "conn", w.id, "err", err, "stack", string(debug.Stack()))
+		}
+	}()
+
+	w.RunTask()
+}
+
+//处理订阅数据格式
+//已经订阅的主题使用原来的连接, 否则分配到订阅最少的连接
+//热备订阅分配到两个不同的连接
+//每次订阅增加主题的引用计数, 交易所不支持的订阅直接忽略
+func (g *workerGroup) subscribeHandle(s *Subscriber) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	topic, sub := g.workers[0].handler.formatSubscribeHandle(s)
+	if topic == "" {
+		logger().Warn("不支持的订阅", "organize", g.Organize, "symbol", s.Symbol, "data_type", s.DataType)
+		return
+	}
+	g.refs[topic]++
+
+	g.levelLock.Lock()
+	if s.DataType == DepthData {
+		g.depthLevels[topic] = s.DepthLevel
+	}
+	g.subscribers[topic] = s
+	g.levelLock.Unlock()
+
+	g.watch(topic, s)
+
+	replicas := 1
+	if s.Redundant {
+		replicas = 2
+		g.seqs.standby(s.Organize, s.DataType.eventType(), s.Symbol)
+	}
+
+	for _, w := range g.workers {
+		if w.hasTopic(topic) {
+			w.subscribeTopic(topic, sub)
+			replicas--
+		}
+	}
+	for ; replicas > 0; replicas-- {
+		g.assign(topic, sub, nil)
+	}
+}
+
+//取消订阅
+//减少主题的引用计数, 最后一个订阅取消时所有订阅该主题的连接发送取消订阅, 不再检测停止推送
+func (g *workerGroup) unsubscribeHandle(s *Subscriber) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	topic, _ := g.workers[0].handler.formatSubscribeHandle(s)
+	if topic == "" {
+		return
+	}
+	if g.refs[topic]--; g.refs[topic] > 0 {
+		return
+	}
+	delete(g.refs, topic)
+
+	g.levelLock.Lock()
+	delete(g.depthLevels, topic)
+	delete(g.subscribers, topic)
+	g.levelLock.Unlock()
+
+	delete(g.watches, topic)
+	for _, w := range g.workers {
+		w.unsubscribeTopic(topic)
+	}
+}
+
+//分配一个主题到订阅最少并且没有超过上限的连接
+//已经订阅该主题的连接不参与分配, 保证热备订阅在不同连接
+//没有可用的连接时创建新的连接
+//调用方需要持有lock
+func (g *workerGroup) assign(topic string, sub []byte, exclude *Worker) {
+	var target *Worker
+	min := -1
+	for _, w := range g.workers {
+		if w == exclude || w.hasTopic(topic) {
+			continue
+		}
+
+		n := w.topicCount()
+		if MaxTopicsPerConn > 0 && n >= MaxTopicsPerConn {
+			continue
+		}
+		if min == -1 || n < min {
+			target, min = w, n
+		}
+	}
+
+	if target == nil {
+		target = g.add()
+		logger().Info("创建新连接", "organize", g.Organize, "conn", target.id)
+	}
+	target.subscribeTopic(topic, sub)
+}
+
+//重连后重新分配该连接的订阅
+//保留平均数量的主题, 多出的主题分配到其他连接
+func (g *workerGroup) rebalance(w *Worker) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if len(g.workers) < 2 {
+		return
+	}
+
+	total := 0
+	for _, v := range g.workers {
+		total += v.topicCount()
+	}
+
+	keep := (total + len(g.workers) - 1) / len(g.workers)
+	if MaxTopicsPerConn > 0 && keep > MaxTopicsPerConn {
+		keep = MaxTopicsPerConn
+	}
+
+	for topic, sub := range w.takeExcess(keep) {
+		g.assign(topic, sub, w)
+	}
+}
+
+//返回订阅主题对应的订阅
+func (g *workerGroup) subscriber(topic string) *Subscriber {
+	g.levelLock.RLock()
+	defer g.levelLock.RUnlock()
+
+	return g.subscribers[topic]
+}
+
+//返回订阅主题的深度档位
+func (g *workerGroup) depthLevel(topic string) DepthLevel {
+	g.levelLock.RLock()
+	defer g.levelLock.RUnlock()
+
+	return g.depthLevels[topic]
+}
+
+//返回集合中所有订阅
+//按订阅主题排序
+func (g *workerGroup) subscriptions() []*Subscription {
+	g.levelLock.RLock()
+	topics := make([]string, 0, len(g.subscribers))
+	for topic := range g.subscribers {
+		topics = append(topics, topic)
+	}
+	subscribers := make(map[string]*Subscriber, len(g.subscribers))
+	for k, v := range g.subscribers {
+		subscribers[k] = v
+	}
+	g.levelLock.RUnlock()
+	sort.Strings(topics)
+
+	g.lock.Lock()
+	workers := append([]*Worker(nil), g.workers...)
+	g.lock.Unlock()
+
+	subs := make([]*Subscription, 0, len(topics))
+	for _, topic := range topics {
+		s := subscribers[topic]
+		sub := &Subscription{
+			Organize:   s.Organize,
+			Symbol:     s.Symbol,
+			MarketType: s.MarketType,
+			DataType:   s.DataType,
+			DepthLevel: s.DepthLevel,
+			Topic:      topic,
+		}
+		for _, w := range workers {
+			if w.confirmed(topic) {
+				sub.Confirmed = true
+			}
+		}
+		subs = append(subs, sub)
+	}
+	return subs
+}
@@ -0,0 +1,148 @@
+package market
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWorkerGroup_SubscribeHandle(t *testing.T) {
+	max := MaxTopicsPerConn
+	MaxTopicsPerConn = 2
+	defer func() {
+		MaxTopicsPerConn = max
+	}()
+
+	g := newWorkerGroup(context.Background(), newOkEx)
+	for _, symbol := range []string{"BTC-USDT", "ETH-USDT", "EOS-USDT", "LTC-USDT", "BTC-USDT"} {
+		g.subscribeHandle(&Subscriber{
+			Symbol:     symbol,
+			Organize:   OkEx,
+			MarketType: SpotMarket,
+		})
+	}
+
+	if len(g.workers) != 2 {
+		t.Fatal(len(g.workers))
+	}
+	for _, w := range g.workers {
+		if w.topicCount() != 2 {
+			t.Fatal(w.id, w.topicCount())
+		}
+	}
+}
+
+func TestWorkerGroup_Rebalance(t *testing.T) {
+	g := newWorkerGroup(context.Background(), newOkEx)
+	for _, symbol := range []string{"BTC-USDT", "ETH-USDT", "EOS-USDT", "LTC-USDT"} {
+		g.workers[0].subscribeTopic("spot/depth5:"+symbol, nil)
+	}
+	g.add()
+
+	g.rebalance(g.workers[0])
+	if g.workers[0].topicCount() != 2 || g.workers[1].topicCount() != 2 {
+		t.Fatal(g.workers[0].topicCount(), g.workers[1].topicCount())
+	}
+}
+
+func TestWorkerGroup_Redundant(t *testing.T) {
+	g := newWorkerGroup(context.Background(), newOkEx)
+	g.subscribeHandle(&Subscriber{
+		Symbol:     "BTC-USDT",
+		Organize:   OkEx,
+		MarketType: SpotMarket,
+		Redundant:  true,
+	})
+
+	if len(g.workers) != 2 {
+		t.Fatal(len(g.workers))
+	}
+	for _, w := range g.workers {
+		if !w.hasTopic("spot/depth5:BTC-USDT") {
+			t.Fatal(w.id)
+		}
+	}
+}
+
+func TestWorkerGroup_UnsubscribeHandle(t *testing.T) {
+	g := newWorkerGroup(context.Background(), newOkEx)
+	s := &Subscriber{Symbol: "BTC-USDT", Organize: OkEx, MarketType: SpotMarket, Redundant: true}
+	g.subscribeHandle(s)
+	g.subscribeHandle(&Subscriber{Symbol: "ETH-USDT", Organize: OkEx, MarketType: SpotMarket})
+
+	g.unsubscribeHandle(s)
+	for _, w := range g.workers {
+		if w.hasTopic("spot/depth5:BTC-USDT") {
+			t.Fatal(w.id)
+		}
+	}
+	if subs := g.subscriptions(); len(subs) != 1 || subs[0].Symbol != "ETH-USDT" {
+		t.Fatal(subs)
+	}
+}
+
+func TestWorkerGroup_UnsubscribeRefs(t *testing.T) {
+	g := newWorkerGroup(context.Background(), newOkEx)
+	s := &Subscriber{Symbol: "BTC-USDT", Organize: OkEx, MarketType: SpotMarket}
+	g.subscribeHandle(s)
+	g.subscribeHandle(s)
+
+	//还有一个订阅使用该主题
+	g.unsubscribeHandle(s)
+	if !g.workers[0].hasTopic("spot/depth5:BTC-USDT") || len(g.subscriptions()) != 1 {
+		t.Fatal(g.subscriptions())
+	}
+
+	g.unsubscribeHandle(s)
+	if g.workers[0].hasTopic("spot/depth5:BTC-USDT") || len(g.subscriptions()) != 0 {
+		t.Fatal(g.subscriptions())
+	}
+}
+
+func TestWorkerGroup_DepthLevel(t *testing.T) {
+	g := newWorkerGroup(context.Background(), newOkEx)
+	spot := &Subscriber{Symbol: "BTC-USD", Organize: OkEx, MarketType: SpotMarket, DepthLevel: Depth20}
+	swap := &Subscriber{Symbol: "BTC-USD", Organize: OkEx, MarketType: WapMarket, DepthLevel: Depth5}
+	g.subscribeHandle(spot)
+	g.subscribeHandle(swap)
+
+	//同一个币对不同市场的档位分别记录
+	if g.depthLevel("spot/depth:BTC-USD") != Depth20 || g.depthLevel("swap/depth5:BTC-USD") != Depth5 {
+		t.Fatal(g.depthLevels)
+	}
+
+	g.unsubscribeHandle(spot)
+	if _, ok := g.depthLevels["spot/depth:BTC-USD"]; ok || g.depthLevel("swap/depth5:BTC-USD") != Depth5 {
+		t.Fatal(g.depthLevels)
+	}
+}
+
+func TestWorkerGroup_DispatchDepthLevel(t *testing.T) {
+	g := newWorkerGroup(context.Background(), newHuoBi)
+	g.subscribeHandle(&Subscriber{Symbol: "lvlusdt", Organize: HuoBi, MarketType: SpotMarket, DepthLevel: Depth5})
+	g.subscribeHandle(&Subscriber{Symbol: "lvlusdt", Organize: HuoBi, MarketType: SpotMarket, DepthLevel: Depth20, DepthStep: Step0})
+	w := g.workers[0]
+	h := w.handler.(*huoBiHandler)
+
+	//同一个币对按各自主题的档位截取
+	levels := `[[6,1],[5,1],[4,1],[3,1],[2,1],[1,1]]`
+	for ch, want := range map[string]int{"market.lvlusdt.depth.step1": 5, "market.lvlusdt.depth.step0": 6} {
+		m, err := h.marketerMsg([]byte(`{"ch":"` + ch + `","ts":1583049600123,"tick":{"bids":` + levels + `,"asks":` + levels + `}}`))
+		if err := w.dispatch(m, err, time.Now(), 0, nil); err != nil {
+			t.Fatal(err)
+		}
+		if len(m.BuyDepth) != want || len(m.SellDepth) != want {
+			t.Fatal(ch, m)
+		}
+	}
+}
+
+func TestWorkerGroup_Status(t *testing.T) {
+	g := newWorkerGroup(context.Background(), newOkEx)
+	g.subscribeHandle(&Subscriber{Symbol: "BTC-USDT", Organize: OkEx, MarketType: SpotMarket})
+
+	s := g.status()
+	if len(s.Conns) != 1 || s.Conns[0].State != WorkerConnecting || len(s.Conns[0].Pending) != 1 {
+		t.Fatal(s.Conns[0])
+	}
+}
@@ -0,0 +1,275 @@
+package market
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative marketpb/market.proto
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/zhaocong6/market/marketpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+//每个grpc推送流等待发送的数据数量
+//超过后视为慢连接, 关闭该推送流
+const grpcStreamBuffer = 256
+
+//grpc推送流
+type grpcStream struct {
+	key  gatewayStream //和ws转发使用相同的数据流
+	send chan Eventer
+	slow chan struct{}
+	once sync.Once
+}
+
+//grpc行情服务
+//实现marketpb.MarketServer, 使用SetGrpcServer后开始推送数据
+//推送流关闭后取消该流的交易所订阅, 交易所订阅按主题引用计数, 不影响其他订阅
+type GrpcServer struct {
+	marketpb.UnimplementedMarketServer
+	streams map[*grpcStream]bool
+	lock    sync.RWMutex
+}
+
+//创建grpc行情服务
+//可以使用marketpb.RegisterMarketServer注册到调用方已有的grpc服务
+func NewGrpcServer() *GrpcServer {
+	return &GrpcServer{
+		streams: make(map[*grpcStream]bool),
+	}
+}
+
+//设置grpc行情服务
+//nil停止推送
+func SetGrpcServer(s *GrpcServer) {
+	Manage.grpc.Store(s)
+}
+
+//当前使用的grpc行情服务
+func grpcServer() *GrpcServer {
+	s, _ := Manage.grpc.Load().(*GrpcServer)
+	return s
+}
+
+//运行grpc行情服务
+//context关闭后停止服务
+func RunGrpcServer(ctx context.Context, addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	s := NewGrpcServer()
+	SetGrpcServer(s)
+	server := grpc.NewServer()
+	marketpb.RegisterMarketServer(server, s)
+
+	go func() {
+		<-ctx.Done()
+		server.Stop()
+	}()
+	return server.Serve(lis)
+}
+
+//推送深度行情
+func (s *GrpcServer) StreamDepth(req *marketpb.StreamRequest, stream grpc.ServerStreamingServer[marketpb.Marketer]) error {
+	sub := streamSubscriber(req, DepthData)
+	return s.stream(stream.Context(), sub, func(e Eventer) error {
+		if m, ok := e.(*Marketer); ok {
+			return stream.Send(toProtoMarketer(m))
+		}
+		return nil
+	})
+}
+
+//推送逐笔成交
+//交易所成交数据还没有接入, 强平成交使用StreamLiquidations
+func (s *GrpcServer) StreamTrades(req *marketpb.StreamRequest, stream grpc.ServerStreamingServer[marketpb.Trade]) error {
+	return status.Error(codes.Unimplemented, "不支持逐笔成交, 强平成交使用StreamLiquidations")
+}
+
+//推送强平成交
+func (s *GrpcServer) StreamLiquidations(req *marketpb.StreamRequest, stream grpc.ServerStreamingServer[marketpb.Trade]) error {
+	sub := streamSubscriber(req, LiquidationData)
+	return s.stream(stream.Context(), sub, func(e Eventer) error {
+		if l, ok := e.(*Liquidation); ok {
+			return stream.Send(toProtoTrade(l))
+		}
+		return nil
+	})
+}
+
+//查询当前深度行情
+func (s *GrpcServer) GetSnapshot(ctx context.Context, req *marketpb.SnapshotRequest) (*marketpb.Marketer, error) {
+	l := lister(Organize(req.Organize))
+	if l == nil {
+		return nil, status.Error(codes.NotFound, "不支持的交易所 "+req.Organize)
+	}
+
+	m, ok := l.Find(req.Symbol).ToMap()[req.Symbol]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "没有行情数据 "+req.Symbol)
+	}
+	return toProtoMarketer(m), nil
+}
+
+//查询所有ws订阅
+func (s *GrpcServer) ListSubscriptions(ctx context.Context, req *marketpb.ListSubscriptionsRequest) (*marketpb.ListSubscriptionsResponse, error) {
+	subs := Subscriptions()
+	resp := &marketpb.ListSubscriptionsResponse{
+		Subscriptions: make([]*marketpb.Subscription, 0, len(subs)),
+	}
+	for _, v := range subs {
+		resp.Subscriptions = append(resp.Subscriptions, &marketpb.Subscription{
+			Organize:   string(v.Organize),
+			Symbol:     v.Symbol,
+			MarketType: int32(v.MarketType),
+			DataType:   int32(v.DataType),
+			DepthLevel: int32(v.DepthLevel),
+			Topic:      v.Topic,
+			Confirmed:  v.Confirmed,
+		})
+	}
+	return resp, nil
+}
+
+//订阅并推送一个数据流
+//context关闭, 发送失败或者推送队列已满时返回, 返回后取消订阅
+func (s *GrpcServer) stream(ctx context.Context, sub *Subscriber, send func(Eventer) error) error {
+	if sub.Organize == "" || sub.Symbol == "" {
+		return status.Error(codes.InvalidArgument, "缺少organize或者symbol")
+	}
+
+	st := &grpcStream{
+		key:  newGatewayKey(sub).gatewayStream,
+		send: make(chan Eventer, grpcStreamBuffer),
+		slow: make(chan struct{}),
+	}
+	s.lock.Lock()
+	s.streams[st] = true
+	s.lock.Unlock()
+	defer func() {
+		s.lock.Lock()
+		delete(s.streams, st)
+		s.lock.Unlock()
+	}()
+
+	select {
+	case WriteSubscribing <- sub:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() {
+		WriteUnsubscribing <- sub
+	}()
+
+	for {
+		select {
+		case e := <-st.send:
+			if err := send(e); err != nil {
+				return err
+			}
+		case <-st.slow:
+			return status.Error(codes.ResourceExhausted, "推送队列已满")
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+//推送一个事件到订阅的grpc推送流
+func (s *GrpcServer) publish(data Eventer) {
+	if s == nil {
+		return
+	}
+
+	b := data.Base()
+	key := gatewayStream{organize: b.Organize, symbol: b.Symbol, stream: b.Type}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	for st := range s.streams {
+		if st.key != key {
+			continue
+		}
+
+		select {
+		case st.send <- data:
+		default:
+			st.once.Do(func() {
+				logger().Warn("grpc推送队列已满, 关闭推送流", "organize", b.Organize, "symbol", b.Symbol)
+				close(st.slow)
+			})
+		}
+	}
+}
+
+//转换为订阅
+func streamSubscriber(req *marketpb.StreamRequest, dataType DataType) *Subscriber {
+	return &Subscriber{
+		Symbol:     req.Symbol,
+		Organize:   Organize(req.Organize),
+		MarketType: MarketType(req.MarketType),
+		DataType:   dataType,
+		DepthLevel: DepthLevel(req.DepthLevel),
+		DepthStep:  DepthStep(req.DepthStep),
+	}
+}
+
+//转换为protobuf深度行情
+func toProtoMarketer(m *Marketer) *marketpb.Marketer {
+	return &marketpb.Marketer{
+		Organize:      string(m.Organize),
+		Symbol:        m.Symbol,
+		BuyFirst:      m.BuyFirst,
+		BuyFirstSize:  m.BuyFirstSize,
+		SellFirst:     m.SellFirst,
+		SellFirstSize: m.SellFirstSize,
+		BuyDepth:      toProtoLevels(m.BuyDepth),
+		SellDepth:     toProtoLevels(m.SellDepth),
+		Timestamp:     toProtoTime(m.Timestamp),
+		Temporize:     durationpb.New(m.Temporize),
+		Latency:       durationpb.New(m.Latency),
+		ReceivedAt:    toProtoTime(m.ReceivedAt),
+		Seq:           m.Seq,
+		ExchangeSeq:   m.ExchangeSeq,
+	}
+}
+
+//强平成交转换为protobuf成交
+func toProtoTrade(l *Liquidation) *marketpb.Trade {
+	return &marketpb.Trade{
+		Organize:    string(l.Organize),
+		Symbol:      l.Symbol,
+		Timestamp:   toProtoTime(l.Timestamp),
+		Seq:         l.Seq,
+		ExchangeSeq: l.ExchangeSeq,
+		Side:        l.Side,
+		Price:       l.Price,
+		Size:        l.Size,
+		Liquidation: true,
+	}
+}
+
+func toProtoLevels(d Depth) []*marketpb.Level {
+	levels := make([]*marketpb.Level, len(d))
+	for k, v := range d {
+		levels[k] = &marketpb.Level{Price: v[0], Size: v[1]}
+	}
+	return levels
+}
+
+//零值返回nil
+func toProtoTime(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
@@ -0,0 +1,170 @@
+package market
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/zhaocong6/market/marketpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+//启动内存grpc服务, 返回客户端
+func newGrpcClient(t *testing.T) marketpb.MarketClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	s := NewGrpcServer()
+	SetGrpcServer(s)
+	server := grpc.NewServer()
+	marketpb.RegisterMarketServer(server, s)
+	go server.Serve(lis)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		server.Stop()
+		SetGrpcServer(nil)
+	})
+	return marketpb.NewMarketClient(conn)
+}
+
+//读取一个上游取消订阅
+func waitUnsubscribing(t *testing.T) *Subscriber {
+	t.Helper()
+
+	select {
+	case s := <-readUnsubscribing:
+		return s
+	case <-time.After(time.Second):
+		t.Fatal("没有收到取消订阅")
+	}
+	return nil
+}
+
+//读取一个上游订阅
+func waitSubscribing(t *testing.T) *Subscriber {
+	t.Helper()
+
+	select {
+	case s := <-readSubscribing:
+		return s
+	case <-time.After(time.Second):
+		t.Fatal("没有收到订阅")
+	}
+	return nil
+}
+
+func TestGrpcServer_StreamDepth(t *testing.T) {
+	client := newGrpcClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.StreamDepth(ctx, &marketpb.StreamRequest{Organize: "okex", Symbol: "GRPC-USDT", MarketType: 1, DepthLevel: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := waitSubscribing(t); s.Symbol != "GRPC-USDT" || s.DataType != DepthData || s.DepthLevel != Depth5 {
+		t.Fatal(s)
+	}
+
+	ts := time.Date(2020, 3, 1, 8, 0, 0, 0, time.UTC)
+	publish(&Marketer{Organize: OkEx, Symbol: "OTHER-USDT"})
+	publish(&Marketer{Organize: OkEx, Symbol: "GRPC-USDT", BuyFirst: "9000", BuyDepth: Depth{{"9000", "1.5"}}, Timestamp: ts, Latency: time.Millisecond, Seq: 3})
+	m, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Symbol != "GRPC-USDT" || m.BuyFirst != "9000" || m.BuyDepth[0].Size != "1.5" || !m.Timestamp.AsTime().Equal(ts) ||
+		m.Latency.AsDuration() != time.Millisecond || m.Seq != 3 || m.ReceivedAt != nil {
+		t.Fatal(m)
+	}
+
+	//客户端关闭后取消订阅
+	cancel()
+	if s := waitUnsubscribing(t); s.Symbol != "GRPC-USDT" || s.DataType != DepthData || s.DepthLevel != Depth5 {
+		t.Fatal(s)
+	}
+}
+
+func TestGrpcServer_StreamTrades(t *testing.T) {
+	client := newGrpcClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.StreamTrades(ctx, &marketpb.StreamRequest{Organize: "huobi", Symbol: "BTC-USD", MarketType: 3})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.Unimplemented {
+		t.Fatal(err)
+	}
+}
+
+func TestGrpcServer_StreamLiquidations(t *testing.T) {
+	client := newGrpcClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.StreamLiquidations(ctx, &marketpb.StreamRequest{Organize: "huobi", Symbol: "BTC-USD", MarketType: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := waitSubscribing(t); s.DataType != LiquidationData {
+		t.Fatal(s)
+	}
+
+	publish(&Liquidation{Event: Event{Type: LiquidationEvent, Organize: HuoBi, Symbol: "BTC-USD"}, Side: "sell", Price: "9000", Size: "2"})
+	trade, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trade.Side != "sell" || trade.Price != "9000" || trade.Size != "2" || !trade.Liquidation {
+		t.Fatal(trade)
+	}
+	cancel()
+	if s := waitUnsubscribing(t); s.Symbol != "BTC-USD" || s.DataType != LiquidationData {
+		t.Fatal(s)
+	}
+
+	stream, err = client.StreamLiquidations(context.Background(), &marketpb.StreamRequest{Symbol: "BTC-USD"})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatal(err)
+	}
+}
+
+func TestGrpcServer_GetSnapshot(t *testing.T) {
+	client := newGrpcClient(t)
+
+	Manage.tasks[HuoBi].List.Add("grpcusdt", &Marketer{Organize: HuoBi, Symbol: "grpcusdt", SellFirst: "1.2", Timestamp: time.Now()})
+	defer Manage.tasks[HuoBi].List.Del("grpcusdt")
+
+	m, err := client.GetSnapshot(context.Background(), &marketpb.SnapshotRequest{Organize: "huobi", Symbol: "grpcusdt"})
+	if err != nil || m.SellFirst != "1.2" {
+		t.Fatal(m, err)
+	}
+	if _, err := client.GetSnapshot(context.Background(), &marketpb.SnapshotRequest{Organize: "huobi", Symbol: "xxx"}); status.Code(err) != codes.NotFound {
+		t.Fatal(err)
+	}
+	if _, err := client.GetSnapshot(context.Background(), &marketpb.SnapshotRequest{Organize: "xxx", Symbol: "grpcusdt"}); status.Code(err) != codes.NotFound {
+		t.Fatal(err)
+	}
+
+	if _, err := client.ListSubscriptions(context.Background(), &marketpb.ListSubscriptionsRequest{}); err != nil {
+		t.Fatal(err)
+	}
+}
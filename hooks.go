@@ -0,0 +1,79 @@
+package market
+
+import (
+	"time"
+)
+
+//生命周期回调
+//回调在连接协程或者协程池中同步执行, 不能阻塞
+//不需要的回调保持nil
+type Hooks struct {
+	OnConnect         func(organize Organize, conn int)                                             //连接成功
+	OnDisconnect      func(organize Organize, conn int, err error)                                  //连接断开, err为断开原因
+	OnReconnect       func(organize Organize, conn int, reconnects int64)                           //重连成功, reconnects为累计重连次数
+	OnSubscribed      func(organize Organize, symbol, topic string)                                 //订阅成功
+	OnSubscribeFailed func(organize Organize, symbol, topic string, err error)                      //交易所返回订阅失败, 之后会继续重新订阅
+	OnDecodeError     func(organize Organize, raw []byte, err error)                                //解析数据失败, raw为ws原始数据
+	OnStale           func(organize Organize, symbol string, stream EventType, quiet time.Duration) //数据流停止推送
+}
+
+//设置生命周期回调
+//nil取消回调
+func SetHooks(h *Hooks) {
+	Manage.hooks.Store(h)
+}
+
+//当前使用的回调
+func hooks() *Hooks {
+	h, _ := Manage.hooks.Load().(*Hooks)
+	return h
+}
+
+func (h *Hooks) connect(w *Worker) {
+	if h == nil || h.OnConnect == nil {
+		return
+	}
+	h.OnConnect(w.Organize, w.id)
+}
+
+func (h *Hooks) disconnect(w *Worker, err error) {
+	if h == nil || h.OnDisconnect == nil {
+		return
+	}
+	h.OnDisconnect(w.Organize, w.id, err)
+}
+
+func (h *Hooks) reconnect(w *Worker, reconnects int64) {
+	if h == nil || h.OnReconnect == nil {
+		return
+	}
+	h.OnReconnect(w.Organize, w.id, reconnects)
+}
+
+func (h *Hooks) subscribed(organize Organize, symbol, topic string) {
+	if h == nil || h.OnSubscribed == nil {
+		return
+	}
+	h.OnSubscribed(organize, symbol, topic)
+}
+
+func (h *Hooks) subscribeFailed(organize Organize, symbol, topic string, err error) {
+	if h == nil || h.OnSubscribeFailed == nil {
+		return
+	}
+	h.OnSubscribeFailed(organize, symbol, topic, err)
+}
+
+func (h *Hooks) decodeError(organize Organize, raw []byte, err error) {
+	if h == nil || h.OnDecodeError == nil {
+		return
+	}
+	h.OnDecodeError(organize, raw, err)
+}
+
+func (h *Hooks) stale(organize Organize, symbol string, stream EventType, quiet time.Duration) {
+	if h == nil || h.OnStale == nil {
+		return
+	}
+	h.OnStale(organize, symbol, stream, quiet)
+}
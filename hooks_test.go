@@ -0,0 +1,39 @@
+package market
+
+import (
+	"context"
+	"testing"
+)
+
+func TestHooks_Subscribe(t *testing.T) {
+	var subscribed, failed string
+	SetHooks(&Hooks{
+		OnSubscribed: func(organize Organize, symbol, topic string) {
+			subscribed = symbol
+		},
+		OnSubscribeFailed: func(organize Organize, symbol, topic string, err error) {
+			failed = symbol + " " + err.Error()
+		},
+	})
+	defer SetHooks(nil)
+
+	g := newWorkerGroup(context.Background(), newHuoBi)
+	for _, symbol := range []string{"btcusdt", "ethusdt"} {
+		g.subscribeHandle(&Subscriber{
+			Symbol:     symbol,
+			Organize:   HuoBi,
+			MarketType: SpotMarket,
+		})
+	}
+
+	w := g.workers[0]
+	w.handler.subscribed([]byte(`{"id":"market.btcusdt.depth.step1","status":"ok","subbed":"market.btcusdt.depth.step1"}`), w)
+	w.handler.subscribed([]byte(`{"id":"market.ethusdt.depth.step1","status":"error","err-code":"bad-request","err-msg":"invalid topic"}`), w)
+
+	if subscribed != "btcusdt" || failed != "ethusdt bad-request invalid topic" {
+		t.Fatal(subscribed, failed)
+	}
+	if !w.hasTopic("market.ethusdt.depth.step1") {
+		t.Fatal("订阅失败的主题应该继续重新订阅")
+	}
+}
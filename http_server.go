@@ -0,0 +1,173 @@
+package market
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//http查询服务
+//GET  /markets?symbol=a&symbol=b   所有交易所中的币对, 按交易所返回
+//GET  /markets/{organize}          交易所所有币对, 和Lister.MarshalJson相同
+//GET  /markets/{organize}/{symbol} 一个币对
+//GET  /health                      运行状态, 没有就绪时返回503
+//GET  /subscriptions               所有ws订阅
+//POST /subscriptions               新增订阅
+type httpServer struct {
+	mux *http.ServeMux
+}
+
+//新增订阅请求
+type subscribeRequest struct {
+	Organize   Organize   `json:"organize"`
+	Symbol     string     `json:"symbol"`
+	MarketType MarketType `json:"market_type"`
+	DataType   DataType   `json:"data_type"`
+	DepthLevel DepthLevel `json:"depth_level"`
+	DepthStep  DepthStep  `json:"depth_step"`
+	Redundant  bool       `json:"redundant"`
+	StaleAfter int64      `json:"stale_after"` //停止推送检测时间(毫秒)
+}
+
+//创建http查询服务
+//可以挂载到调用方已有的http服务
+func NewHttpHandler() http.Handler {
+	s := &httpServer{mux: http.NewServeMux()}
+	s.mux.HandleFunc("/markets", s.markets)
+	s.mux.HandleFunc("/markets/", s.market)
+	s.mux.HandleFunc("/health", s.health)
+	s.mux.HandleFunc("/subscriptions", s.subscriptions)
+	return s.mux
+}
+
+//运行http查询服务
+//context关闭后停止服务
+func RunHttpServer(ctx context.Context, addr string) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           NewHttpHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+
+	err := server.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+//跨交易所查询币对
+func (s *httpServer) markets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeHttpError(w, http.StatusMethodNotAllowed, errors.New("不支持的请求方法"))
+		return
+	}
+
+	symbols := r.URL.Query()["symbol"]
+	if len(symbols) == 0 {
+		writeHttpError(w, http.StatusBadRequest, errors.New("缺少symbol参数"))
+		return
+	}
+
+	markets := make(map[Organize]json.RawMessage, len(listOrganizes))
+	for _, organize := range listOrganizes {
+		markets[organize] = json.RawMessage(lister(organize).Find(symbols...).MarshalJson())
+	}
+	writeHttpJson(w, http.StatusOK, markets)
+}
+
+//查询一个交易所的币对
+func (s *httpServer) market(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeHttpError(w, http.StatusMethodNotAllowed, errors.New("不支持的请求方法"))
+		return
+	}
+
+	path := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/markets/"), "/"), "/")
+	l := lister(Organize(path[0]))
+	if l == nil || len(path) > 2 {
+		writeHttpError(w, http.StatusNotFound, errors.New("不支持的交易所 "+path[0]))
+		return
+	}
+
+	if len(path) == 1 {
+		writeHttpJson(w, http.StatusOK, json.RawMessage(l.MarshalJson()))
+		return
+	}
+
+	m, ok := l.Find(path[1]).ToMap()[path[1]]
+	if !ok {
+		writeHttpError(w, http.StatusNotFound, errors.New("没有行情数据 "+path[1]))
+		return
+	}
+	writeHttpJson(w, http.StatusOK, m)
+}
+
+//运行状态
+func (s *httpServer) health(w http.ResponseWriter, r *http.Request) {
+	status := Status()
+	code := http.StatusOK
+	if !status.Ready {
+		code = http.StatusServiceUnavailable
+	}
+	writeHttpJson(w, code, status)
+}
+
+//查询, 新增和取消订阅
+func (s *httpServer) subscriptions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		subs := Subscriptions()
+		if subs == nil {
+			subs = []*Subscription{}
+		}
+		writeHttpJson(w, http.StatusOK, subs)
+	case http.MethodPost:
+		s.writeSubscriber(w, r, WriteSubscribing)
+	case http.MethodDelete:
+		s.writeSubscriber(w, r, WriteUnsubscribing)
+	default:
+		writeHttpError(w, http.StatusMethodNotAllowed, errors.New("不支持的请求方法"))
+	}
+}
+
+//解析请求中的订阅, 写入订阅或者取消订阅的channel
+func (s *httpServer) writeSubscriber(w http.ResponseWriter, r *http.Request, ch chan<- *Subscriber) {
+	req := &subscribeRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		writeHttpError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Symbol == "" || req.Organize == "" {
+		writeHttpError(w, http.StatusBadRequest, errors.New("缺少organize或者symbol"))
+		return
+	}
+
+	select {
+	case ch <- req.subscriber():
+		writeHttpJson(w, http.StatusAccepted, req)
+	case <-r.Context().Done():
+	}
+}
+
+func writeHttpJson(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger().Warn("http返回数据失败", "err", err)
+	}
+}
+
+func writeHttpError(w http.ResponseWriter, code int, err error) {
+	writeHttpJson(w, code, map[string]string{"error": err.Error()})
+}
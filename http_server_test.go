@@ -0,0 +1,126 @@
+package market
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+//请求http服务并解析返回的json
+func httpGetJson(t *testing.T, url string, v interface{}) int {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode
+}
+
+func TestHttpServer_Markets(t *testing.T) {
+	server := httptest.NewServer(NewHttpHandler())
+	defer server.Close()
+
+	Manage.tasks[OkEx].List.Add("HTTP-USDT", &Marketer{Organize: OkEx, Symbol: "HTTP-USDT", BuyFirst: "1.5", Timestamp: time.Now()})
+	Manage.tasks[HuoBi].List.Add("httpusdt", &Marketer{Organize: HuoBi, Symbol: "httpusdt", BuyFirst: "1.6", Timestamp: time.Now()})
+	defer Manage.tasks[OkEx].List.Del("HTTP-USDT")
+	defer Manage.tasks[HuoBi].List.Del("httpusdt")
+
+	all := map[string]map[string]interface{}{}
+	if code := httpGetJson(t, server.URL+"/markets/okex", &all); code != http.StatusOK || all["HTTP-USDT"]["buy_first"] != "1.5" {
+		t.Fatal(code, all)
+	}
+
+	one := map[string]interface{}{}
+	if code := httpGetJson(t, server.URL+"/markets/huobi/httpusdt", &one); code != http.StatusOK || one["buy_first"] != "1.6" {
+		t.Fatal(code, one)
+	}
+	if code := httpGetJson(t, server.URL+"/markets/huobi/xxx", &one); code != http.StatusNotFound {
+		t.Fatal(code)
+	}
+	if code := httpGetJson(t, server.URL+"/markets/huobi_index", &one); code != http.StatusNotFound {
+		t.Fatal(code)
+	}
+
+	cross := map[Organize]map[string]map[string]interface{}{}
+	code := httpGetJson(t, server.URL+"/markets?symbol=HTTP-USDT&symbol=httpusdt", &cross)
+	if code != http.StatusOK || cross[OkEx]["HTTP-USDT"] == nil || cross[HuoBi]["httpusdt"] == nil || len(cross[OkEx]) != 1 {
+		t.Fatal(code, cross)
+	}
+}
+
+func TestHttpServer_Subscriptions(t *testing.T) {
+	server := httptest.NewServer(NewHttpHandler())
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/subscriptions", "application/json", strings.NewReader(`{"organize":"huobi","symbol":"btcusdt","market_type":1,"depth_level":20}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatal(resp.StatusCode)
+	}
+
+	select {
+	case s := <-readSubscribing:
+		if s.Organize != HuoBi || s.Symbol != "btcusdt" || s.MarketType != SpotMarket || s.DepthLevel != Depth20 {
+			t.Fatal(s)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("没有收到订阅")
+	}
+
+	//取消订阅使用和新增相同的格式
+	req, _ := http.NewRequest(http.MethodDelete, server.URL+"/subscriptions", strings.NewReader(`{"organize":"huobi","symbol":"btcusdt","market_type":1,"depth_level":20}`))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatal(resp.StatusCode)
+	}
+	if s := waitUnsubscribing(t); s.Organize != HuoBi || s.Symbol != "btcusdt" || s.DepthLevel != Depth20 {
+		t.Fatal(s)
+	}
+
+	resp, err = http.Post(server.URL+"/subscriptions", "application/json", strings.NewReader(`{"symbol":"btcusdt"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatal(resp.StatusCode)
+	}
+
+	var subs []*Subscription
+	if code := httpGetJson(t, server.URL+"/subscriptions", &subs); code != http.StatusOK {
+		t.Fatal(code)
+	}
+}
+
+func TestWorkerGroup_Subscriptions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	g := newWorkerGroup(ctx, newOkEx)
+	g.subscribeHandle(&Subscriber{Symbol: "BTC-USDT", Organize: OkEx, MarketType: SpotMarket, DepthLevel: Depth5})
+	g.workers[0].subscribed("spot/depth5:BTC-USDT")
+	g.subscribeHandle(&Subscriber{Symbol: "ETH-USDT", Organize: OkEx, MarketType: SpotMarket, DataType: BBOData})
+
+	subs := g.subscriptions()
+	if len(subs) != 2 || subs[0].Topic != "spot/depth5:BTC-USDT" || !subs[0].Confirmed || subs[0].DepthLevel != Depth5 ||
+		subs[1].Symbol != "ETH-USDT" || subs[1].Confirmed {
+		t.Fatal(subs[0], subs[1])
+	}
+}
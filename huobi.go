@@ -4,8 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/gorilla/websocket"
-	"log"
 	"strings"
 	"time"
 )
@@ -27,55 +27,105 @@ func newHuoBi(ctx context.Context) *Worker {
 			pingLastTime: time.Now().Unix(),
 		},
 		Organize:         HuoBi,
-		Status:           runIng,
+		Status:           WorkerConnecting,
 		Subscribes:       make(map[string][]byte),
 		Subscribing:      make(map[string][]byte),
-		LastRunTimestamp: time.Duration(time.Now().UnixNano() / 1e6),
+		LastRunTimestamp: time.Now(),
 		WsConn:           nil,
 		List:             newList(),
+		sequencer:        newSequencer(),
 	}
 }
 
-func (h *huoBiHandler) formatSubscribeHandle(s *Subscriber) (b []byte) {
-	switch s.MarketType {
-	case SpotMarket:
-		b = []byte(`{"id":"id1","sub":"market.` + s.Symbol + `.depth.step1"}`)
-	case FuturesMarket:
-	case OptionMarket:
-	case WapMarket:
+func (h *huoBiHandler) formatSubscribeHandle(s *Subscriber) (topic string, b []byte) {
+	switch s.DataType {
+	case DepthData:
+		switch s.MarketType {
+		case SpotMarket:
+			topic = "market." + s.Symbol + ".depth." + string(h.depthStep(s))
+		case FuturesMarket:
+		case OptionMarket:
+		case WapMarket:
+		}
+	case BBOData:
+		if s.MarketType == SpotMarket {
+			topic = "market." + s.Symbol + ".bbo"
+		}
+	case MarkPriceData:
+		if s.MarketType == WapMarket {
+			topic = "market." + s.Symbol + ".mark_price." + huobiKlinePeriod
+		}
+	case IndexPriceData:
+		topic = "market." + s.Symbol + ".index." + huobiKlinePeriod
 	}
 
+	if topic != "" {
+		b = []byte(`{"id":"` + topic + `","sub":"` + topic + `"}`)
+	}
 	return
 }
 
+func (h *huoBiHandler) formatUnsubscribeHandle(topic string) []byte {
+	return []byte(`{"id":"id1","unsub":"` + topic + `"}`)
+}
+
+//火币深度合并精度
+//step0最多150档, 其他精度最多20档
+//FullDepth也使用step0, 最多150档
+func (h *huoBiHandler) depthStep(s *Subscriber) DepthStep {
+	if s.DepthStep != DefaultDepthStep {
+		return s.DepthStep
+	}
+	if s.DepthLevel == FullDepth || s.DepthLevel > Depth20 {
+		return Step0
+	}
+
+	return Step1
+}
+
+//订阅返回结构体
+//订阅时id为订阅主题, 用于匹配订阅失败的主题
 type huobiSubscriber struct {
-	Status string `json:"status"`
-	Subbed string `json:"subbed"`
+	Id      string `json:"id"`
+	Status  string `json:"status"`
+	Subbed  string `json:"subbed"`
+	ErrCode string `json:"err-code"`
+	ErrMsg  string `json:"err-msg"`
 }
 
 func (h *huoBiHandler) subscribed(msg []byte, w *Worker) {
 	subscribe := &huobiSubscriber{}
-	json.Unmarshal(msg, subscribe)
-	if subscribe.Status == "ok" {
-		w.subscribed(strings.Split(subscribe.Subbed, ".")[1])
+	if err := json.Unmarshal(msg, subscribe); err != nil {
+		logger().Warn("解析订阅返回失败", "organize", w.Organize, "conn", w.id, "err", err)
+		return
+	}
+	switch subscribe.Status {
+	case "ok":
+		w.subscribed(subscribe.Subbed)
+	case "error":
+		w.subscribeFailed(subscribe.Id, fmt.Errorf("%s %s", subscribe.ErrCode, subscribe.ErrMsg))
 	}
 }
 
 func (h *huoBiHandler) pingPongHandle(w *Worker) {
 	for {
 		select {
+		case <-w.ctx.Done():
+			return
 		case <-time.NewTimer(time.Second * time.Duration(huobiPingCheck)).C:
 			if (time.Now().Unix() - h.pingLastTime) > huobiWsPingTimeout {
-				log.Printf("%s pingpong断线", HuoBi)
-				w.closeRedialSub()
+				logger().Warn("pingpong断线", "organize", w.Organize, "conn", w.id)
+				w.closeRedialSub(errPingTimeout)
 			} else {
 				pong, _ := json.Marshal(struct {
-					Pong time.Duration `json:"pong"`
+					Pong int64 `json:"pong"`
 				}{
-					Pong: time.Duration(time.Now().UnixNano() / 1e6),
+					Pong: millisecond(time.Now()),
 				})
 
-				w.writeMessage(websocket.TextMessage, pong)
+				if err := w.writeMessage(websocket.TextMessage, pong); err != nil {
+					logger().Warn("发送pong失败", "organize", w.Organize, "conn", w.id, "err", err)
+				}
 			}
 		}
 	}
@@ -85,26 +135,55 @@ func (h *huobiProvider) setSymbol() {
 	h.Symbol = strings.Split(h.Ch, ".")[1]
 }
 
-func (h *huoBiHandler) formatMsgHandle(msgType int, msg []byte, w *Worker) (*Marketer, error) {
+//火币只处理gzip压缩的二进制数据
+func (h *huoBiHandler) decodeMsgHandle(msgType int, msg []byte) ([]byte, error) {
 	switch msgType {
 	case websocket.BinaryMessage:
-		msg, err := gzipDecode(msg)
-		if err != nil {
-			return nil, err
-		}
+		return gzipDecode(msg)
+	default:
+		return nil, nil
+	}
+}
 
-		market, err := h.marketerMsg(msg)
+func (h *huoBiHandler) formatMsgHandle(msg []byte, w *Worker) (Eventer, error) {
+	event, err := h.chMsg(msg)
+	if err != errNotPush {
+		return event, err
+	}
 
-		if err == nil {
-			return market, err
-		}
+	h.pongMsg(msg)
+	h.subscribed(msg, w)
+	return nil, nil
+}
 
-		h.pongMsg(msg)
-		h.subscribed(msg, w)
-		return nil, nil
-	default:
-		return nil, nil
+//火币ch数据结构体
+//用于判断推送的数据类型
+type huobiCh struct {
+	Ch string `json:"ch"`
+}
+
+//根据ch分发到不同的数据类型
+func (h *huoBiHandler) chMsg(msg []byte) (Eventer, error) {
+	ch := &huobiCh{}
+	err := json.Unmarshal(msg, ch)
+	if err != nil {
+		return nil, err
 	}
+
+	switch {
+	case ch.Ch == "":
+		return nil, errNotPush
+	case strings.Contains(ch.Ch, ".depth."):
+		return h.marketerMsg(msg)
+	case strings.HasSuffix(ch.Ch, ".bbo"):
+		return h.bboMsg(msg)
+	case strings.Contains(ch.Ch, ".mark_price."):
+		return h.markPriceMsg(msg)
+	case strings.Contains(ch.Ch, ".index."):
+		return h.indexPriceMsg(msg)
+	}
+
+	return nil, nil
 }
 
 type huobiProvider struct {
@@ -113,10 +192,11 @@ type huobiProvider struct {
 	Tick   struct {
 		Bids      [][2]float64 `json:"bids"`
 		Asks      [][2]float64 `json:"asks"`
+		Version   uint64       `json:"version"`
 		bidsDepth Depth
 		asksDepth Depth
 	} `json:"tick"`
-	Timestamp time.Duration `json:"ts"`
+	Timestamp int64 `json:"ts"`
 }
 
 func (h *huoBiHandler) marketerMsg(msg []byte) (*Marketer, error) {
@@ -140,14 +220,56 @@ func (h *huoBiHandler) marketerMsg(msg []byte) (*Marketer, error) {
 
 func (h *huoBiHandler) newMarketer(p *huobiProvider) (*Marketer, error) {
 	return &Marketer{
-		Organize:  HuoBi,
-		Symbol:    p.Symbol,
-		BuyFirst:  p.Tick.bidsDepth[0][0],
-		SellFirst: p.Tick.asksDepth[0][0],
-		BuyDepth:  p.Tick.bidsDepth,
-		SellDepth: p.Tick.asksDepth,
-		Timestamp: p.Timestamp,
-		Temporize: time.Duration(time.Now().UnixNano()/1e6) - p.Timestamp,
+		Organize:      HuoBi,
+		Symbol:        p.Symbol,
+		topic:         p.Ch,
+		BuyFirst:      p.Tick.bidsDepth[0][0],
+		BuyFirstSize:  p.Tick.bidsDepth[0][1],
+		SellFirst:     p.Tick.asksDepth[0][0],
+		SellFirstSize: p.Tick.asksDepth[0][1],
+		BuyDepth:      p.Tick.bidsDepth,
+		SellDepth:     p.Tick.asksDepth,
+		Timestamp:     fromMillisecond(p.Timestamp),
+		ExchangeSeq:   p.Tick.Version,
+	}, nil
+}
+
+//火币最优买卖价结构体
+type huobiBBO struct {
+	Tick struct {
+		Symbol  string      `json:"symbol"`
+		Bid     json.Number `json:"bid"`
+		BidSize json.Number `json:"bidSize"`
+		Ask     json.Number `json:"ask"`
+		AskSize json.Number `json:"askSize"`
+		SeqId   uint64      `json:"seqId"`
+	} `json:"tick"`
+	Timestamp int64 `json:"ts"`
+}
+
+//解析最优买卖价
+func (h *huoBiHandler) bboMsg(msg []byte) (*BBO, error) {
+	huobiData := &huobiBBO{}
+	err := json.Unmarshal(msg, huobiData)
+	if err != nil {
+		return nil, err
+	}
+	if huobiData.Tick.Bid == "" || huobiData.Tick.Ask == "" {
+		return nil, errors.New("序列化最优买卖价错误")
+	}
+
+	return &BBO{
+		Event: Event{
+			Type:        BBOEvent,
+			Organize:    HuoBi,
+			Symbol:      huobiData.Tick.Symbol,
+			Timestamp:   fromMillisecond(huobiData.Timestamp),
+			ExchangeSeq: huobiData.Tick.SeqId,
+		},
+		BidPrice: huobiData.Tick.Bid.String(),
+		BidSize:  huobiData.Tick.BidSize.String(),
+		AskPrice: huobiData.Tick.Ask.String(),
+		AskSize:  huobiData.Tick.AskSize.String(),
 	}, nil
 }
 
@@ -157,7 +279,10 @@ type huobiPing struct {
 
 func (h *huoBiHandler) pongMsg(msg []byte) {
 	huobiPing := &huobiPing{}
-	json.Unmarshal(msg, huobiPing)
+	if err := json.Unmarshal(msg, huobiPing); err != nil {
+		logger().Warn("解析ping失败", "organize", HuoBi, "err", err)
+		return
+	}
 	if huobiPing.Ping != 0 {
 		h.pingLastTime = time.Now().Unix()
 	}
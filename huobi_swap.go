@@ -0,0 +1,396 @@
+package market
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//火币永续指数ws地址
+//指数价格和标记价格
+var huoBiIndexUrl = "wss://api.hbdm.com/ws_index"
+
+//火币永续公共通知ws地址
+//资金费率
+var huoBiNotifyUrl = "wss://api.hbdm.com/swap-notification"
+
+//火币交割公共通知ws地址
+//强平订单
+var huoBiFuturesNotifyUrl = "wss://api.hbdm.com/notification"
+
+//火币合约rest地址
+var huoBiRestUrl = "https://api.hbdm.com"
+
+//火币永续指数worker
+const huoBiIndex Organize = "huobi_index"
+
+//火币永续公共通知worker
+const huoBiNotify Organize = "huobi_notify"
+
+//火币交割公共通知worker
+const huoBiFuturesNotify Organize = "huobi_futures_notify"
+
+//火币k线周期
+//指数和标记价格只有k线推送, 取收盘价
+const huobiKlinePeriod = "1min"
+
+//创建一个火币永续指数worker
+//和火币现货使用相同的协议
+func newHuoBiIndex(ctx context.Context) *Worker {
+	w := newHuoBi(ctx)
+	w.wsUrl = huoBiIndexUrl
+	w.Organize = huoBiIndex
+	return w
+}
+
+//根据订阅数据类型找到火币对应的worker
+func huoBiRoute(s *Subscriber) Organize {
+	switch s.DataType {
+	case FundingRateData:
+		return huoBiNotify
+	case LiquidationData:
+		if s.MarketType == FuturesMarket {
+			return huoBiFuturesNotify
+		}
+		return huoBiNotify
+	case MarkPriceData, IndexPriceData:
+		return huoBiIndex
+	}
+
+	return HuoBi
+}
+
+//火币k线结构体
+type huobiKline struct {
+	Ch   string `json:"ch"`
+	Tick struct {
+		Close json.Number `json:"close"`
+	} `json:"tick"`
+	Timestamp int64 `json:"ts"`
+}
+
+//解析k线, 返回合约和收盘价
+func (h *huoBiHandler) klineMsg(msg []byte) (*huobiKline, string, error) {
+	huobiData := &huobiKline{}
+	err := json.Unmarshal(msg, huobiData)
+	if err != nil {
+		return nil, "", err
+	}
+	if huobiData.Tick.Close == "" {
+		return nil, "", errors.New("序列化k线错误")
+	}
+
+	return huobiData, strings.Split(huobiData.Ch, ".")[1], nil
+}
+
+//解析标记价格
+func (h *huoBiHandler) markPriceMsg(msg []byte) (*MarkPrice, error) {
+	k, symbol, err := h.klineMsg(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MarkPrice{
+		Event: Event{
+			Type:      MarkPriceEvent,
+			Organize:  HuoBi,
+			Symbol:    symbol,
+			Timestamp: fromMillisecond(k.Timestamp),
+		},
+		MarkPrice: k.Tick.Close.String(),
+	}, nil
+}
+
+//解析指数价格
+func (h *huoBiHandler) indexPriceMsg(msg []byte) (*IndexPrice, error) {
+	k, symbol, err := h.klineMsg(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &IndexPrice{
+		Event: Event{
+			Type:      IndexPriceEvent,
+			Organize:  HuoBi,
+			Symbol:    symbol,
+			Timestamp: fromMillisecond(k.Timestamp),
+		},
+		IndexPrice: k.Tick.Close.String(),
+	}, nil
+}
+
+//火币公共通知handler
+//协议和行情ws不同, 使用op区分消息
+type huoBiNotifyHandler struct {
+	pingLastTime int64
+}
+
+//创建一个火币永续公共通知worker
+func newHuoBiNotify(ctx context.Context) *Worker {
+	return &Worker{
+		ctx:   ctx,
+		wsUrl: huoBiNotifyUrl,
+		handler: &huoBiNotifyHandler{
+			pingLastTime: time.Now().Unix(),
+		},
+		Organize:         huoBiNotify,
+		Status:           WorkerConnecting,
+		Subscribes:       make(map[string][]byte),
+		Subscribing:      make(map[string][]byte),
+		LastRunTimestamp: time.Now(),
+		WsConn:           nil,
+		List:             newList(),
+		sequencer:        newSequencer(),
+	}
+}
+
+//创建一个火币交割公共通知worker
+//交割合约的symbol为品种代码, 如BTC
+func newHuoBiFuturesNotify(ctx context.Context) *Worker {
+	w := newHuoBiNotify(ctx)
+	w.wsUrl = huoBiFuturesNotifyUrl
+	w.Organize = huoBiFuturesNotify
+	return w
+}
+
+func (h *huoBiNotifyHandler) formatSubscribeHandle(s *Subscriber) (topic string, b []byte) {
+	switch s.DataType {
+	case FundingRateData:
+		if s.MarketType == WapMarket {
+			topic = "public." + s.Symbol + ".funding_rate"
+		}
+	case LiquidationData:
+		if s.MarketType == WapMarket || s.MarketType == FuturesMarket {
+			topic = "public." + s.Symbol + ".liquidation_orders"
+		}
+	}
+
+	if topic != "" {
+		b = []byte(`{"op":"sub","cid":"id1","topic":"` + topic + `"}`)
+	}
+	return
+}
+
+func (h *huoBiNotifyHandler) formatUnsubscribeHandle(topic string) []byte {
+	return []byte(`{"op":"unsub","cid":"id1","topic":"` + topic + `"}`)
+}
+
+//公共通知由服务器发起ping
+//超过规定时间没有收到ping就断开重连
+func (h *huoBiNotifyHandler) pingPongHandle(w *Worker) {
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-time.NewTimer(time.Second * time.Duration(huobiPingCheck)).C:
+			if (time.Now().Unix() - h.pingLastTime) > huobiWsPingTimeout {
+				logger().Warn("pingpong断线", "organize", w.Organize, "conn", w.id)
+				w.closeRedialSub(errPingTimeout)
+			}
+		}
+	}
+}
+
+//火币公共通知消息结构体
+type huobiNotifyMsg struct {
+	Op      string          `json:"op"`
+	Topic   string          `json:"topic"`
+	ErrCode int             `json:"err-code"`
+	ErrMsg  string          `json:"err-msg"`
+	Ts      json.Number     `json:"ts"`
+	Data    json.RawMessage `json:"data"`
+}
+
+//公共通知只处理gzip压缩的二进制数据
+func (h *huoBiNotifyHandler) decodeMsgHandle(msgType int, msg []byte) ([]byte, error) {
+	switch msgType {
+	case websocket.BinaryMessage:
+		return gzipDecode(msg)
+	default:
+		return nil, nil
+	}
+}
+
+func (h *huoBiNotifyHandler) formatMsgHandle(msg []byte, w *Worker) (Eventer, error) {
+	notify := &huobiNotifyMsg{}
+	err := json.Unmarshal(msg, notify)
+	if err != nil {
+		return nil, err
+	}
+
+	switch notify.Op {
+	case "ping":
+		h.pingLastTime = time.Now().Unix()
+		err = w.writeMessage(websocket.TextMessage, []byte(`{"op":"pong","ts":`+strconv.Quote(notify.Ts.String())+`}`))
+		if err != nil {
+			logger().Warn("发送pong失败", "organize", w.Organize, "conn", w.id, "err", err)
+		}
+	case "sub":
+		h.subscribed(msg, w)
+	case "notify":
+		switch {
+		case strings.HasSuffix(notify.Topic, ".funding_rate"):
+			return h.fundingRateMsg(notify)
+		case strings.HasSuffix(notify.Topic, ".liquidation_orders"):
+			return h.liquidationMsg(notify)
+		}
+	}
+	return nil, nil
+}
+
+func (h *huoBiNotifyHandler) subscribed(msg []byte, w *Worker) {
+	notify := &huobiNotifyMsg{}
+	if err := json.Unmarshal(msg, notify); err != nil {
+		logger().Warn("解析订阅返回失败", "organize", w.Organize, "conn", w.id, "err", err)
+		return
+	}
+	if notify.Op != "sub" {
+		return
+	}
+	if notify.ErrCode != 0 {
+		w.subscribeFailed(notify.Topic, fmt.Errorf("%d %s", notify.ErrCode, notify.ErrMsg))
+		return
+	}
+	w.subscribed(notify.Topic)
+}
+
+//火币资金费率结构体
+type huobiFundingRate struct {
+	ContractCode   string `json:"contract_code"`   //合约
+	FundingRate    string `json:"funding_rate"`    //当期资金费率
+	EstimatedRate  string `json:"estimated_rate"`  //预测资金费率
+	SettlementTime string `json:"settlement_time"` //下次结算时间(毫秒)
+}
+
+//解析资金费率
+func (h *huoBiNotifyHandler) fundingRateMsg(notify *huobiNotifyMsg) (*FundingRate, error) {
+	var data []huobiFundingRate
+	err := json.Unmarshal(notify.Data, &data)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("序列化资金费率错误")
+	}
+
+	ts, _ := notify.Ts.Int64()
+	settlement, _ := strconv.ParseInt(data[0].SettlementTime, 10, 64)
+
+	return &FundingRate{
+		Event: Event{
+			Type:      FundingRateEvent,
+			Organize:  HuoBi,
+			Symbol:    data[0].ContractCode,
+			Timestamp: fromMillisecond(ts),
+		},
+		FundingRate:     data[0].FundingRate,
+		EstimatedRate:   data[0].EstimatedRate,
+		NextFundingTime: fromMillisecond(settlement),
+	}, nil
+}
+
+//火币强平订单结构体
+type huobiLiquidation struct {
+	Symbol       string      `json:"symbol"`        //品种代码
+	ContractCode string      `json:"contract_code"` //合约代码
+	Direction    string      `json:"direction"`     //强平订单方向
+	Volume       json.Number `json:"volume"`        //强平数量(张)
+	Price        json.Number `json:"price"`         //强平价格
+	CreatedAt    int64       `json:"created_at"`    //强平时间(毫秒)
+}
+
+//解析强平订单
+//一次推送可能包含多个订单
+func (h *huoBiNotifyHandler) liquidationMsg(notify *huobiNotifyMsg) (eventBatch, error) {
+	var data []huobiLiquidation
+	err := json.Unmarshal(notify.Data, &data)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("序列化强平订单错误")
+	}
+
+	batch := make(eventBatch, 0, len(data))
+	for _, d := range data {
+		symbol := d.ContractCode
+		if symbol == "" {
+			symbol = d.Symbol
+		}
+
+		batch = append(batch, &Liquidation{
+			Event: Event{
+				Type:      LiquidationEvent,
+				Organize:  HuoBi,
+				Symbol:    symbol,
+				Timestamp: fromMillisecond(d.CreatedAt),
+			},
+			Side:  d.Direction,
+			Price: d.Price.String(),
+			Size:  d.Volume.String(),
+		})
+	}
+	return batch, nil
+}
+
+//火币rest轮询handler
+//火币ws没有持仓量数据
+type huoBiPollHandler struct{}
+
+func (h *huoBiPollHandler) formatPollHandle(s *Subscriber) (topic string, url string) {
+	switch s.DataType {
+	case OpenInterestData:
+		switch s.MarketType {
+		case WapMarket:
+			topic = "swap_open_interest." + s.Symbol
+			url = huoBiRestUrl + "/swap-api/v1/swap_open_interest?contract_code=" + s.Symbol
+		case FuturesMarket:
+			topic = "contract_open_interest." + s.Symbol
+			url = huoBiRestUrl + "/api/v1/contract_open_interest?contract_code=" + s.Symbol
+		}
+	}
+
+	return
+}
+
+//火币持仓量结构体
+type huobiOpenInterest struct {
+	Status string `json:"status"`
+	Data   []struct {
+		ContractCode string      `json:"contract_code"` //合约代码
+		Volume       json.Number `json:"volume"`        //持仓量(张)
+		Amount       json.Number `json:"amount"`        //持仓量(币)
+	} `json:"data"`
+	Timestamp int64 `json:"ts"`
+}
+
+func (h *huoBiPollHandler) formatPollMsg(topic string, body []byte) ([]Eventer, error) {
+	huobiData := &huobiOpenInterest{}
+	err := json.Unmarshal(body, huobiData)
+	if err != nil {
+		return nil, err
+	}
+	if huobiData.Status != "ok" {
+		return nil, errors.New("序列化持仓量错误")
+	}
+
+	events := make([]Eventer, 0, len(huobiData.Data))
+	for _, d := range huobiData.Data {
+		events = append(events, &OpenInterest{
+			Event: Event{
+				Type:      OpenInterestEvent,
+				Organize:  HuoBi,
+				Symbol:    d.ContractCode,
+				Timestamp: fromMillisecond(huobiData.Timestamp),
+			},
+			Volume: d.Volume.String(),
+			Amount: d.Amount.String(),
+		})
+	}
+	return events, nil
+}
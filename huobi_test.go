@@ -0,0 +1,31 @@
+package market
+
+import (
+	"testing"
+)
+
+func TestHuoBiHandler_BboMsg(t *testing.T) {
+	h := &huoBiHandler{}
+
+	e, err := h.bboMsg([]byte(`{"ch":"market.btcusdt.bbo","ts":1583049600123,"tick":{"symbol":"btcusdt","quoteTime":1583049600120,"bid":9000.1,"bidSize":0.5,"ask":9000.2,"askSize":1.25,"seqId":1001}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Type != BBOEvent || e.Organize != HuoBi || e.Symbol != "btcusdt" || e.BidPrice != "9000.1" || e.BidSize != "0.5" ||
+		e.AskPrice != "9000.2" || e.AskSize != "1.25" || e.ExchangeSeq != 1001 || millisecond(e.Timestamp) != 1583049600123 {
+		t.Fatal(e)
+	}
+
+	//深度行情的买一卖一数量
+	m, err := h.marketerMsg([]byte(`{"ch":"market.ethusdt.depth.step1","ts":1583049600123,"tick":{"bids":[[230.1,2]],"asks":[[230.2,1.5]],"version":100}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Symbol != "ethusdt" || m.BuyFirst != "230.1" || m.BuyFirstSize != "2" || m.SellFirst != "230.2" || m.SellFirstSize != "1.5" {
+		t.Fatal(m)
+	}
+
+	if _, err := h.bboMsg([]byte(`{"ch":"market.btcusdt.bbo","ts":1583049600123,"tick":{}}`)); err == nil {
+		t.Fatal("没有返回错误")
+	}
+}
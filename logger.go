@@ -0,0 +1,115 @@
+package market
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+//分级日志接口
+//kv为键值对, 例如 "organize", OkEx, "conn", 0
+//*slog.Logger可以直接使用, zap使用NewZapLogger适配
+type Logger interface {
+	Debug(msg string, kv ...interface{})
+	Info(msg string, kv ...interface{})
+	Warn(msg string, kv ...interface{})
+	Error(msg string, kv ...interface{})
+}
+
+//日志级别
+type LogLevel int
+
+const LogDebug LogLevel = -4
+const LogInfo LogLevel = 0
+const LogWarn LogLevel = 4
+const LogError LogLevel = 8
+
+func (l LogLevel) String() string {
+	switch {
+	case l < LogInfo:
+		return "DEBUG"
+	case l < LogWarn:
+		return "INFO"
+	case l < LogError:
+		return "WARN"
+	default:
+		return "ERROR"
+	}
+}
+
+//标准库log适配
+//低于level的日志不输出
+type stdLogger struct {
+	logger *log.Logger
+	level  LogLevel
+}
+
+//使用标准库log输出日志
+//默认日志为标准错误输出, INFO级别
+func NewStdLogger(logger *log.Logger, level LogLevel) Logger {
+	return &stdLogger{
+		logger: logger,
+		level:  level,
+	}
+}
+
+func (l *stdLogger) output(level LogLevel, msg string, kv []interface{}) {
+	if level < l.level {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(level.String())
+	b.WriteString(" ")
+	b.WriteString(msg)
+	for i := 0; i < len(kv); i += 2 {
+		if i+1 < len(kv) {
+			fmt.Fprintf(&b, " %v=%v", kv[i], kv[i+1])
+		} else {
+			fmt.Fprintf(&b, " %v", kv[i])
+		}
+	}
+	l.logger.Output(3, b.String())
+}
+
+func (l *stdLogger) Debug(msg string, kv ...interface{}) {
+	l.output(LogDebug, msg, kv)
+}
+
+func (l *stdLogger) Info(msg string, kv ...interface{}) {
+	l.output(LogInfo, msg, kv)
+}
+
+func (l *stdLogger) Warn(msg string, kv ...interface{}) {
+	l.output(LogWarn, msg, kv)
+}
+
+func (l *stdLogger) Error(msg string, kv ...interface{}) {
+	l.output(LogError, msg, kv)
+}
+
+//默认日志
+var defaultLogger = NewStdLogger(log.New(os.Stderr, "", log.LstdFlags), LogInfo)
+
+//atomic.Value要求每次写入相同的类型
+type loggerValue struct {
+	Logger
+}
+
+//设置日志
+//nil恢复默认日志
+func SetLogger(l Logger) {
+	if l == nil {
+		l = defaultLogger
+	}
+	Manage.logger.Store(loggerValue{l})
+}
+
+//当前使用的日志
+func logger() Logger {
+	if v, ok := Manage.logger.Load().(loggerValue); ok {
+		return v.Logger
+	}
+	return defaultLogger
+}
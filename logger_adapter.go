@@ -0,0 +1,42 @@
+package market
+
+import (
+	"log/slog"
+
+	"go.uber.org/zap"
+)
+
+//slog适配
+//*slog.Logger本身满足Logger接口, 这里只是为了和zap保持一致
+func NewSlogLogger(l *slog.Logger) Logger {
+	return l
+}
+
+//zap适配
+type zapLogger struct {
+	sugar *zap.SugaredLogger
+}
+
+//使用zap输出日志
+//kv转换为zap的字段
+func NewZapLogger(l *zap.Logger) Logger {
+	return &zapLogger{
+		sugar: l.WithOptions(zap.AddCallerSkip(1)).Sugar(),
+	}
+}
+
+func (l *zapLogger) Debug(msg string, kv ...interface{}) {
+	l.sugar.Debugw(msg, kv...)
+}
+
+func (l *zapLogger) Info(msg string, kv ...interface{}) {
+	l.sugar.Infow(msg, kv...)
+}
+
+func (l *zapLogger) Warn(msg string, kv ...interface{}) {
+	l.sugar.Warnw(msg, kv...)
+}
+
+func (l *zapLogger) Error(msg string, kv ...interface{}) {
+	l.sugar.Errorw(msg, kv...)
+}
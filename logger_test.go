@@ -0,0 +1,35 @@
+package market
+
+import (
+	"bytes"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestStdLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := NewStdLogger(log.New(buf, "", 0), LogInfo)
+
+	l.Debug("不输出")
+	l.Warn("断线重连", "organize", OkEx, "conn", 1)
+
+	if buf.String() != "WARN 断线重连 organize=okex conn=1\n" {
+		t.Fatal(buf.String())
+	}
+}
+
+func TestSetLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	SetLogger(NewSlogLogger(slog.New(slog.NewTextHandler(buf, nil))))
+	defer SetLogger(nil)
+
+	h := &okexHandler{}
+	w := newOkEx(nil)
+	h.subscribed([]byte("{"), w)
+
+	if !strings.Contains(buf.String(), "level=WARN") || !strings.Contains(buf.String(), "organize=okex") {
+		t.Fatal(buf.String())
+	}
+}
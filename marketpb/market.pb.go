@@ -0,0 +1,782 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: marketpb/market.proto
+
+package marketpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// 一档深度
+type Level struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Price         string                 `protobuf:"bytes,1,opt,name=price,proto3" json:"price,omitempty"`
+	Size          string                 `protobuf:"bytes,2,opt,name=size,proto3" json:"size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Level) Reset() {
+	*x = Level{}
+	mi := &file_marketpb_market_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Level) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Level) ProtoMessage() {}
+
+func (x *Level) ProtoReflect() protoreflect.Message {
+	mi := &file_marketpb_market_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Level.ProtoReflect.Descriptor instead.
+func (*Level) Descriptor() ([]byte, []int) {
+	return file_marketpb_market_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Level) GetPrice() string {
+	if x != nil {
+		return x.Price
+	}
+	return ""
+}
+
+func (x *Level) GetSize() string {
+	if x != nil {
+		return x.Size
+	}
+	return ""
+}
+
+// 深度行情, 和market.Marketer相同
+type Marketer struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Organize      string                 `protobuf:"bytes,1,opt,name=organize,proto3" json:"organize,omitempty"`                                  //交易所
+	Symbol        string                 `protobuf:"bytes,2,opt,name=symbol,proto3" json:"symbol,omitempty"`                                      //订阅币对
+	BuyFirst      string                 `protobuf:"bytes,3,opt,name=buy_first,json=buyFirst,proto3" json:"buy_first,omitempty"`                  //买一价格
+	BuyFirstSize  string                 `protobuf:"bytes,4,opt,name=buy_first_size,json=buyFirstSize,proto3" json:"buy_first_size,omitempty"`    //买一数量
+	SellFirst     string                 `protobuf:"bytes,5,opt,name=sell_first,json=sellFirst,proto3" json:"sell_first,omitempty"`               //卖一价格
+	SellFirstSize string                 `protobuf:"bytes,6,opt,name=sell_first_size,json=sellFirstSize,proto3" json:"sell_first_size,omitempty"` //卖一数量
+	BuyDepth      []*Level               `protobuf:"bytes,7,rep,name=buy_depth,json=buyDepth,proto3" json:"buy_depth,omitempty"`                  //市场买深度
+	SellDepth     []*Level               `protobuf:"bytes,8,rep,name=sell_depth,json=sellDepth,proto3" json:"sell_depth,omitempty"`               //市场卖深度
+	Timestamp     *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=timestamp,proto3" json:"timestamp,omitempty"`                                //交易所数据更新时间
+	Temporize     *durationpb.Duration   `protobuf:"bytes,10,opt,name=temporize,proto3" json:"temporize,omitempty"`                               //网络延迟, 包含时钟偏移
+	Latency       *durationpb.Duration   `protobuf:"bytes,11,opt,name=latency,proto3" json:"latency,omitempty"`                                   //校正交易所时钟偏移后的网络延迟
+	ReceivedAt    *timestamppb.Timestamp `protobuf:"bytes,12,opt,name=received_at,json=receivedAt,proto3" json:"received_at,omitempty"`           //本地接收时间
+	Seq           uint64                 `protobuf:"varint,13,opt,name=seq,proto3" json:"seq,omitempty"`                                          //本地序号
+	ExchangeSeq   uint64                 `protobuf:"varint,14,opt,name=exchange_seq,json=exchangeSeq,proto3" json:"exchange_seq,omitempty"`       //交易所序号, 交易所不提供时为0
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Marketer) Reset() {
+	*x = Marketer{}
+	mi := &file_marketpb_market_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Marketer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Marketer) ProtoMessage() {}
+
+func (x *Marketer) ProtoReflect() protoreflect.Message {
+	mi := &file_marketpb_market_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Marketer.ProtoReflect.Descriptor instead.
+func (*Marketer) Descriptor() ([]byte, []int) {
+	return file_marketpb_market_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Marketer) GetOrganize() string {
+	if x != nil {
+		return x.Organize
+	}
+	return ""
+}
+
+func (x *Marketer) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+func (x *Marketer) GetBuyFirst() string {
+	if x != nil {
+		return x.BuyFirst
+	}
+	return ""
+}
+
+func (x *Marketer) GetBuyFirstSize() string {
+	if x != nil {
+		return x.BuyFirstSize
+	}
+	return ""
+}
+
+func (x *Marketer) GetSellFirst() string {
+	if x != nil {
+		return x.SellFirst
+	}
+	return ""
+}
+
+func (x *Marketer) GetSellFirstSize() string {
+	if x != nil {
+		return x.SellFirstSize
+	}
+	return ""
+}
+
+func (x *Marketer) GetBuyDepth() []*Level {
+	if x != nil {
+		return x.BuyDepth
+	}
+	return nil
+}
+
+func (x *Marketer) GetSellDepth() []*Level {
+	if x != nil {
+		return x.SellDepth
+	}
+	return nil
+}
+
+func (x *Marketer) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *Marketer) GetTemporize() *durationpb.Duration {
+	if x != nil {
+		return x.Temporize
+	}
+	return nil
+}
+
+func (x *Marketer) GetLatency() *durationpb.Duration {
+	if x != nil {
+		return x.Latency
+	}
+	return nil
+}
+
+func (x *Marketer) GetReceivedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ReceivedAt
+	}
+	return nil
+}
+
+func (x *Marketer) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *Marketer) GetExchangeSeq() uint64 {
+	if x != nil {
+		return x.ExchangeSeq
+	}
+	return 0
+}
+
+// 成交, StreamLiquidations推送的强平成交liquidation为true
+type Trade struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Organize      string                 `protobuf:"bytes,1,opt,name=organize,proto3" json:"organize,omitempty"`                           //交易所
+	Symbol        string                 `protobuf:"bytes,2,opt,name=symbol,proto3" json:"symbol,omitempty"`                               //合约
+	Timestamp     *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`                         //交易所成交时间
+	Seq           uint64                 `protobuf:"varint,4,opt,name=seq,proto3" json:"seq,omitempty"`                                    //本地序号
+	ExchangeSeq   uint64                 `protobuf:"varint,5,opt,name=exchange_seq,json=exchangeSeq,proto3" json:"exchange_seq,omitempty"` //交易所序号
+	Side          string                 `protobuf:"bytes,6,opt,name=side,proto3" json:"side,omitempty"`                                   //成交方向 buy/sell
+	Price         string                 `protobuf:"bytes,7,opt,name=price,proto3" json:"price,omitempty"`                                 //成交价格
+	Size          string                 `protobuf:"bytes,8,opt,name=size,proto3" json:"size,omitempty"`                                   //成交数量(张)
+	Liquidation   bool                   `protobuf:"varint,9,opt,name=liquidation,proto3" json:"liquidation,omitempty"`                    //是否强平成交
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Trade) Reset() {
+	*x = Trade{}
+	mi := &file_marketpb_market_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Trade) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Trade) ProtoMessage() {}
+
+func (x *Trade) ProtoReflect() protoreflect.Message {
+	mi := &file_marketpb_market_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Trade.ProtoReflect.Descriptor instead.
+func (*Trade) Descriptor() ([]byte, []int) {
+	return file_marketpb_market_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Trade) GetOrganize() string {
+	if x != nil {
+		return x.Organize
+	}
+	return ""
+}
+
+func (x *Trade) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+func (x *Trade) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *Trade) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *Trade) GetExchangeSeq() uint64 {
+	if x != nil {
+		return x.ExchangeSeq
+	}
+	return 0
+}
+
+func (x *Trade) GetSide() string {
+	if x != nil {
+		return x.Side
+	}
+	return ""
+}
+
+func (x *Trade) GetPrice() string {
+	if x != nil {
+		return x.Price
+	}
+	return ""
+}
+
+func (x *Trade) GetSize() string {
+	if x != nil {
+		return x.Size
+	}
+	return ""
+}
+
+func (x *Trade) GetLiquidation() bool {
+	if x != nil {
+		return x.Liquidation
+	}
+	return false
+}
+
+// 订阅推送请求, 字段和market.Subscriber相同
+type StreamRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Organize      string                 `protobuf:"bytes,1,opt,name=organize,proto3" json:"organize,omitempty"`
+	Symbol        string                 `protobuf:"bytes,2,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	MarketType    int32                  `protobuf:"varint,3,opt,name=market_type,json=marketType,proto3" json:"market_type,omitempty"`
+	DepthLevel    int32                  `protobuf:"varint,4,opt,name=depth_level,json=depthLevel,proto3" json:"depth_level,omitempty"` //只对StreamDepth有效
+	DepthStep     string                 `protobuf:"bytes,5,opt,name=depth_step,json=depthStep,proto3" json:"depth_step,omitempty"`     //只对StreamDepth有效
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamRequest) Reset() {
+	*x = StreamRequest{}
+	mi := &file_marketpb_market_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamRequest) ProtoMessage() {}
+
+func (x *StreamRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_marketpb_market_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamRequest.ProtoReflect.Descriptor instead.
+func (*StreamRequest) Descriptor() ([]byte, []int) {
+	return file_marketpb_market_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *StreamRequest) GetOrganize() string {
+	if x != nil {
+		return x.Organize
+	}
+	return ""
+}
+
+func (x *StreamRequest) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+func (x *StreamRequest) GetMarketType() int32 {
+	if x != nil {
+		return x.MarketType
+	}
+	return 0
+}
+
+func (x *StreamRequest) GetDepthLevel() int32 {
+	if x != nil {
+		return x.DepthLevel
+	}
+	return 0
+}
+
+func (x *StreamRequest) GetDepthStep() string {
+	if x != nil {
+		return x.DepthStep
+	}
+	return ""
+}
+
+// 查询深度行情请求
+type SnapshotRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Organize      string                 `protobuf:"bytes,1,opt,name=organize,proto3" json:"organize,omitempty"`
+	Symbol        string                 `protobuf:"bytes,2,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SnapshotRequest) Reset() {
+	*x = SnapshotRequest{}
+	mi := &file_marketpb_market_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SnapshotRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SnapshotRequest) ProtoMessage() {}
+
+func (x *SnapshotRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_marketpb_market_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SnapshotRequest.ProtoReflect.Descriptor instead.
+func (*SnapshotRequest) Descriptor() ([]byte, []int) {
+	return file_marketpb_market_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *SnapshotRequest) GetOrganize() string {
+	if x != nil {
+		return x.Organize
+	}
+	return ""
+}
+
+func (x *SnapshotRequest) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+type ListSubscriptionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSubscriptionsRequest) Reset() {
+	*x = ListSubscriptionsRequest{}
+	mi := &file_marketpb_market_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSubscriptionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSubscriptionsRequest) ProtoMessage() {}
+
+func (x *ListSubscriptionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_marketpb_market_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSubscriptionsRequest.ProtoReflect.Descriptor instead.
+func (*ListSubscriptionsRequest) Descriptor() ([]byte, []int) {
+	return file_marketpb_market_proto_rawDescGZIP(), []int{5}
+}
+
+type ListSubscriptionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Subscriptions []*Subscription        `protobuf:"bytes,1,rep,name=subscriptions,proto3" json:"subscriptions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSubscriptionsResponse) Reset() {
+	*x = ListSubscriptionsResponse{}
+	mi := &file_marketpb_market_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSubscriptionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSubscriptionsResponse) ProtoMessage() {}
+
+func (x *ListSubscriptionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_marketpb_market_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSubscriptionsResponse.ProtoReflect.Descriptor instead.
+func (*ListSubscriptionsResponse) Descriptor() ([]byte, []int) {
+	return file_marketpb_market_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ListSubscriptionsResponse) GetSubscriptions() []*Subscription {
+	if x != nil {
+		return x.Subscriptions
+	}
+	return nil
+}
+
+// 一个ws订阅, 和market.Subscription相同
+type Subscription struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Organize      string                 `protobuf:"bytes,1,opt,name=organize,proto3" json:"organize,omitempty"`
+	Symbol        string                 `protobuf:"bytes,2,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	MarketType    int32                  `protobuf:"varint,3,opt,name=market_type,json=marketType,proto3" json:"market_type,omitempty"`
+	DataType      int32                  `protobuf:"varint,4,opt,name=data_type,json=dataType,proto3" json:"data_type,omitempty"`
+	DepthLevel    int32                  `protobuf:"varint,5,opt,name=depth_level,json=depthLevel,proto3" json:"depth_level,omitempty"`
+	Topic         string                 `protobuf:"bytes,6,opt,name=topic,proto3" json:"topic,omitempty"`
+	Confirmed     bool                   `protobuf:"varint,7,opt,name=confirmed,proto3" json:"confirmed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Subscription) Reset() {
+	*x = Subscription{}
+	mi := &file_marketpb_market_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Subscription) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Subscription) ProtoMessage() {}
+
+func (x *Subscription) ProtoReflect() protoreflect.Message {
+	mi := &file_marketpb_market_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Subscription.ProtoReflect.Descriptor instead.
+func (*Subscription) Descriptor() ([]byte, []int) {
+	return file_marketpb_market_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Subscription) GetOrganize() string {
+	if x != nil {
+		return x.Organize
+	}
+	return ""
+}
+
+func (x *Subscription) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+func (x *Subscription) GetMarketType() int32 {
+	if x != nil {
+		return x.MarketType
+	}
+	return 0
+}
+
+func (x *Subscription) GetDataType() int32 {
+	if x != nil {
+		return x.DataType
+	}
+	return 0
+}
+
+func (x *Subscription) GetDepthLevel() int32 {
+	if x != nil {
+		return x.DepthLevel
+	}
+	return 0
+}
+
+func (x *Subscription) GetTopic() string {
+	if x != nil {
+		return x.Topic
+	}
+	return ""
+}
+
+func (x *Subscription) GetConfirmed() bool {
+	if x != nil {
+		return x.Confirmed
+	}
+	return false
+}
+
+var File_marketpb_market_proto protoreflect.FileDescriptor
+
+const file_marketpb_market_proto_rawDesc = "" +
+	"\n" +
+	"\x15marketpb/market.proto\x12\x06market\x1a\x1egoogle/protobuf/duration.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"1\n" +
+	"\x05Level\x12\x14\n" +
+	"\x05price\x18\x01 \x01(\tR\x05price\x12\x12\n" +
+	"\x04size\x18\x02 \x01(\tR\x04size\"\xbc\x04\n" +
+	"\bMarketer\x12\x1a\n" +
+	"\borganize\x18\x01 \x01(\tR\borganize\x12\x16\n" +
+	"\x06symbol\x18\x02 \x01(\tR\x06symbol\x12\x1b\n" +
+	"\tbuy_first\x18\x03 \x01(\tR\bbuyFirst\x12$\n" +
+	"\x0ebuy_first_size\x18\x04 \x01(\tR\fbuyFirstSize\x12\x1d\n" +
+	"\n" +
+	"sell_first\x18\x05 \x01(\tR\tsellFirst\x12&\n" +
+	"\x0fsell_first_size\x18\x06 \x01(\tR\rsellFirstSize\x12*\n" +
+	"\tbuy_depth\x18\a \x03(\v2\r.market.LevelR\bbuyDepth\x12,\n" +
+	"\n" +
+	"sell_depth\x18\b \x03(\v2\r.market.LevelR\tsellDepth\x128\n" +
+	"\ttimestamp\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x127\n" +
+	"\ttemporize\x18\n" +
+	" \x01(\v2\x19.google.protobuf.DurationR\ttemporize\x123\n" +
+	"\alatency\x18\v \x01(\v2\x19.google.protobuf.DurationR\alatency\x12;\n" +
+	"\vreceived_at\x18\f \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"receivedAt\x12\x10\n" +
+	"\x03seq\x18\r \x01(\x04R\x03seq\x12!\n" +
+	"\fexchange_seq\x18\x0e \x01(\x04R\vexchangeSeq\"\x8a\x02\n" +
+	"\x05Trade\x12\x1a\n" +
+	"\borganize\x18\x01 \x01(\tR\borganize\x12\x16\n" +
+	"\x06symbol\x18\x02 \x01(\tR\x06symbol\x128\n" +
+	"\ttimestamp\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12\x10\n" +
+	"\x03seq\x18\x04 \x01(\x04R\x03seq\x12!\n" +
+	"\fexchange_seq\x18\x05 \x01(\x04R\vexchangeSeq\x12\x12\n" +
+	"\x04side\x18\x06 \x01(\tR\x04side\x12\x14\n" +
+	"\x05price\x18\a \x01(\tR\x05price\x12\x12\n" +
+	"\x04size\x18\b \x01(\tR\x04size\x12 \n" +
+	"\vliquidation\x18\t \x01(\bR\vliquidation\"\xa4\x01\n" +
+	"\rStreamRequest\x12\x1a\n" +
+	"\borganize\x18\x01 \x01(\tR\borganize\x12\x16\n" +
+	"\x06symbol\x18\x02 \x01(\tR\x06symbol\x12\x1f\n" +
+	"\vmarket_type\x18\x03 \x01(\x05R\n" +
+	"marketType\x12\x1f\n" +
+	"\vdepth_level\x18\x04 \x01(\x05R\n" +
+	"depthLevel\x12\x1d\n" +
+	"\n" +
+	"depth_step\x18\x05 \x01(\tR\tdepthStep\"E\n" +
+	"\x0fSnapshotRequest\x12\x1a\n" +
+	"\borganize\x18\x01 \x01(\tR\borganize\x12\x16\n" +
+	"\x06symbol\x18\x02 \x01(\tR\x06symbol\"\x1a\n" +
+	"\x18ListSubscriptionsRequest\"W\n" +
+	"\x19ListSubscriptionsResponse\x12:\n" +
+	"\rsubscriptions\x18\x01 \x03(\v2\x14.market.SubscriptionR\rsubscriptions\"\xd5\x01\n" +
+	"\fSubscription\x12\x1a\n" +
+	"\borganize\x18\x01 \x01(\tR\borganize\x12\x16\n" +
+	"\x06symbol\x18\x02 \x01(\tR\x06symbol\x12\x1f\n" +
+	"\vmarket_type\x18\x03 \x01(\x05R\n" +
+	"marketType\x12\x1b\n" +
+	"\tdata_type\x18\x04 \x01(\x05R\bdataType\x12\x1f\n" +
+	"\vdepth_level\x18\x05 \x01(\x05R\n" +
+	"depthLevel\x12\x14\n" +
+	"\x05topic\x18\x06 \x01(\tR\x05topic\x12\x1c\n" +
+	"\tconfirmed\x18\a \x01(\bR\tconfirmed2\xcc\x02\n" +
+	"\x06Market\x128\n" +
+	"\vStreamDepth\x12\x15.market.StreamRequest\x1a\x10.market.Marketer0\x01\x126\n" +
+	"\fStreamTrades\x12\x15.market.StreamRequest\x1a\r.market.Trade0\x01\x12<\n" +
+	"\x12StreamLiquidations\x12\x15.market.StreamRequest\x1a\r.market.Trade0\x01\x128\n" +
+	"\vGetSnapshot\x12\x17.market.SnapshotRequest\x1a\x10.market.Marketer\x12X\n" +
+	"\x11ListSubscriptions\x12 .market.ListSubscriptionsRequest\x1a!.market.ListSubscriptionsResponseB&Z$github.com/zhaocong6/market/marketpbb\x06proto3"
+
+var (
+	file_marketpb_market_proto_rawDescOnce sync.Once
+	file_marketpb_market_proto_rawDescData []byte
+)
+
+func file_marketpb_market_proto_rawDescGZIP() []byte {
+	file_marketpb_market_proto_rawDescOnce.Do(func() {
+		file_marketpb_market_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_marketpb_market_proto_rawDesc), len(file_marketpb_market_proto_rawDesc)))
+	})
+	return file_marketpb_market_proto_rawDescData
+}
+
+var file_marketpb_market_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_marketpb_market_proto_goTypes = []any{
+	(*Level)(nil),                     // 0: market.Level
+	(*Marketer)(nil),                  // 1: market.Marketer
+	(*Trade)(nil),                     // 2: market.Trade
+	(*StreamRequest)(nil),             // 3: market.StreamRequest
+	(*SnapshotRequest)(nil),           // 4: market.SnapshotRequest
+	(*ListSubscriptionsRequest)(nil),  // 5: market.ListSubscriptionsRequest
+	(*ListSubscriptionsResponse)(nil), // 6: market.ListSubscriptionsResponse
+	(*Subscription)(nil),              // 7: market.Subscription
+	(*timestamppb.Timestamp)(nil),     // 8: google.protobuf.Timestamp
+	(*durationpb.Duration)(nil),       // 9: google.protobuf.Duration
+}
+var file_marketpb_market_proto_depIdxs = []int32{
+	0,  // 0: market.Marketer.buy_depth:type_name -> market.Level
+	0,  // 1: market.Marketer.sell_depth:type_name -> market.Level
+	8,  // 2: market.Marketer.timestamp:type_name -> google.protobuf.Timestamp
+	9,  // 3: market.Marketer.temporize:type_name -> google.protobuf.Duration
+	9,  // 4: market.Marketer.latency:type_name -> google.protobuf.Duration
+	8,  // 5: market.Marketer.received_at:type_name -> google.protobuf.Timestamp
+	8,  // 6: market.Trade.timestamp:type_name -> google.protobuf.Timestamp
+	7,  // 7: market.ListSubscriptionsResponse.subscriptions:type_name -> market.Subscription
+	3,  // 8: market.Market.StreamDepth:input_type -> market.StreamRequest
+	3,  // 9: market.Market.StreamTrades:input_type -> market.StreamRequest
+	3,  // 10: market.Market.StreamLiquidations:input_type -> market.StreamRequest
+	4,  // 11: market.Market.GetSnapshot:input_type -> market.SnapshotRequest
+	5,  // 12: market.Market.ListSubscriptions:input_type -> market.ListSubscriptionsRequest
+	1,  // 13: market.Market.StreamDepth:output_type -> market.Marketer
+	2,  // 14: market.Market.StreamTrades:output_type -> market.Trade
+	2,  // 15: market.Market.StreamLiquidations:output_type -> market.Trade
+	1,  // 16: market.Market.GetSnapshot:output_type -> market.Marketer
+	6,  // 17: market.Market.ListSubscriptions:output_type -> market.ListSubscriptionsResponse
+	13, // [13:18] is the sub-list for method output_type
+	8,  // [8:13] is the sub-list for method input_type
+	8,  // [8:8] is the sub-list for extension type_name
+	8,  // [8:8] is the sub-list for extension extendee
+	0,  // [0:8] is the sub-list for field type_name
+}
+
+func init() { file_marketpb_market_proto_init() }
+func file_marketpb_market_proto_init() {
+	if File_marketpb_market_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_marketpb_market_proto_rawDesc), len(file_marketpb_market_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   8,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_marketpb_market_proto_goTypes,
+		DependencyIndexes: file_marketpb_market_proto_depIdxs,
+		MessageInfos:      file_marketpb_market_proto_msgTypes,
+	}.Build()
+	File_marketpb_market_proto = out.File
+	file_marketpb_market_proto_goTypes = nil
+	file_marketpb_market_proto_depIdxs = nil
+}
@@ -0,0 +1,131 @@
+package market
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//prometheus指标
+//调用RegisterMetrics后才会采集
+type metricSet struct {
+	messages     *prometheus.CounterVec   //收到的数据数量, 按交易所和事件类型
+	decodeErrors *prometheus.CounterVec   //解析数据失败数量, 按连接
+	reconnects   *prometheus.CounterVec   //重连次数, 按连接
+	drops        *prometheus.CounterVec   //环形缓冲区删除的数据数量, 按缓冲区
+	queueDepth   prometheus.Gauge         //协程池中等待处理的数据数量
+	temporize    *prometheus.HistogramVec //网络延迟分布(毫秒), 按交易所
+}
+
+//当前使用的指标
+//没有调用RegisterMetrics时返回nil, 不采集指标
+func metrics() *metricSet {
+	m, _ := Manage.metrics.Load().(*metricSet)
+	return m
+}
+
+//注册prometheus指标
+//registerer由调用方提供, 可以使用调用方已有的http服务暴露
+func RegisterMetrics(registerer prometheus.Registerer) error {
+	m := &metricSet{
+		messages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "market",
+			Name:      "messages_total",
+			Help:      "Messages received from exchanges by organize and event type.",
+		}, []string{"organize", "type"}),
+		decodeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "market",
+			Name:      "decode_errors_total",
+			Help:      "Messages that failed to decode by connection organize.",
+		}, []string{"organize"}),
+		reconnects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "market",
+			Name:      "reconnects_total",
+			Help:      "Websocket reconnects by connection organize.",
+		}, []string{"organize"}),
+		drops: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "market",
+			Name:      "ring_buffer_drops_total",
+			Help:      "Entries dropped from a full ring buffer by pool.",
+		}, []string{"pool"}),
+		queueDepth: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "market",
+			Name:      "pool_queue_depth",
+			Help:      "Messages waiting in the goroutine pool.",
+		}),
+		temporize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "market",
+			Name:      "temporize_milliseconds",
+			Help:      "Marketer.Temporize latency distribution by organize.",
+			Buckets:   []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000},
+		}, []string{"organize"}),
+	}
+
+	for _, c := range []prometheus.Collector{m.messages, m.decodeErrors, m.reconnects, m.drops, m.queueDepth, m.temporize} {
+		if err := registerer.Register(c); err != nil {
+			return err
+		}
+	}
+
+	Manage.metrics.Store(m)
+	return nil
+}
+
+//记录收到的数据
+//data为nil时按连接的交易所organize记录为control, 例如pong和订阅返回
+func (m *metricSet) message(organize Organize, data Eventer) {
+	if m == nil {
+		return
+	}
+
+	switch e := data.(type) {
+	case nil:
+		m.messages.WithLabelValues(string(organize), "control").Inc()
+	case *Marketer:
+		m.messages.WithLabelValues(string(e.Organize), string(DepthEvent)).Inc()
+		m.temporize.WithLabelValues(string(e.Organize)).Observe(float64(e.Temporize) / float64(time.Millisecond))
+	case eventBatch:
+		for _, v := range e {
+			m.message(organize, v)
+		}
+	default:
+		b := e.Base()
+		m.messages.WithLabelValues(string(b.Organize), string(b.Type)).Inc()
+	}
+}
+
+//记录解析失败
+func (m *metricSet) decodeError(organize Organize) {
+	if m == nil {
+		return
+	}
+
+	m.decodeErrors.WithLabelValues(string(organize)).Inc()
+}
+
+//记录重连
+func (m *metricSet) reconnect(organize Organize) {
+	if m == nil {
+		return
+	}
+
+	m.reconnects.WithLabelValues(string(organize)).Inc()
+}
+
+//记录环形缓冲区删除数据
+func (m *metricSet) drop(pool string) {
+	if m == nil {
+		return
+	}
+
+	m.drops.WithLabelValues(pool).Inc()
+}
+
+//记录协程池等待数量变化
+func (m *metricSet) queue(delta float64) {
+	if m == nil {
+		return
+	}
+
+	m.queueDepth.Add(delta)
+}
@@ -0,0 +1,55 @@
+package market
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestRegisterMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	if err := RegisterMetrics(reg); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		Manage.metrics.Store((*metricSet)(nil))
+	}()
+
+	m := NewTestMarketer()
+	m.Temporize = 20 * time.Millisecond
+	metrics().message(OkEx, m)
+	metrics().message(HuoBi, eventBatch{&FundingRate{Event: Event{Type: FundingRateEvent, Organize: HuoBi}}})
+	metrics().message(OkEx, nil)
+	metrics().reconnect(OkEx)
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[string]int)
+	control := ""
+	for _, f := range families {
+		counts[f.GetName()] = len(f.GetMetric())
+		for _, v := range f.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range v.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["type"] == "control" {
+				control = labels["organize"]
+			}
+		}
+	}
+	if control != string(OkEx) {
+		t.Fatal(control)
+	}
+	if counts["market_messages_total"] != 3 || counts["market_temporize_milliseconds"] != 1 || counts["market_reconnects_total"] != 1 {
+		t.Fatal(counts)
+	}
+
+	if err := RegisterMetrics(reg); err == nil {
+		t.Fatal("重复注册应该返回错误")
+	}
+}
@@ -0,0 +1,305 @@
+package market
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+//模拟交易所ws服务
+//按交易所协议压缩数据, 处理ping pong和订阅, 可以推送指定数据和主动断开连接
+type mockExchange struct {
+	server   *httptest.Server
+	organize Organize
+	upgrader websocket.Upgrader
+	conns    map[*websocket.Conn]map[string]bool //每个连接订阅的主题
+	reject   map[string]bool                     //返回订阅失败的主题
+	subs     chan string                         //收到的订阅主题
+	lock     sync.Mutex
+}
+
+//创建模拟交易所
+//支持OkEx和HuoBi协议
+func newMockExchange(t *testing.T, organize Organize) *mockExchange {
+	m := &mockExchange{
+		organize: organize,
+		conns:    make(map[*websocket.Conn]map[string]bool),
+		reject:   make(map[string]bool),
+		subs:     make(chan string, 100),
+	}
+	m.server = httptest.NewServer(http.HandlerFunc(m.handle))
+	t.Cleanup(m.close)
+	return m
+}
+
+//ws地址
+func (m *mockExchange) url() string {
+	return "ws" + strings.TrimPrefix(m.server.URL, "http")
+}
+
+func (m *mockExchange) handle(w http.ResponseWriter, r *http.Request) {
+	conn, err := m.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	m.lock.Lock()
+	m.conns[conn] = make(map[string]bool)
+	m.lock.Unlock()
+
+	if m.organize == HuoBi {
+		m.write(conn, `{"ping":`+strconv.FormatInt(time.Now().UnixNano()/1e6, 10)+`}`)
+	}
+
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			m.lock.Lock()
+			delete(m.conns, conn)
+			m.lock.Unlock()
+			return
+		}
+
+		switch m.organize {
+		case OkEx:
+			m.okex(conn, msg)
+		case HuoBi:
+			m.huobi(conn, msg)
+		}
+	}
+}
+
+//okex协议
+//ping返回pong, 订阅返回订阅成功或者失败
+func (m *mockExchange) okex(conn *websocket.Conn, msg []byte) {
+	if string(msg) == "ping" {
+		m.write(conn, "pong")
+		return
+	}
+
+	req := &struct {
+		Op   string   `json:"op"`
+		Args []string `json:"args"`
+	}{}
+	if json.Unmarshal(msg, req) != nil || len(req.Args) == 0 {
+		return
+	}
+
+	topic := req.Args[0]
+	switch {
+	case req.Op == "subscribe" && m.rejected(topic):
+		m.write(conn, `{"event":"error","message":"Channel `+topic+` doesn't exist","errorCode":30040}`)
+	case req.Op == "subscribe":
+		m.subscribe(conn, topic, true)
+		m.subs <- topic
+		m.write(conn, `{"event":"subscribe","channel":"`+topic+`"}`)
+	case req.Op == "unsubscribe":
+		m.subscribe(conn, topic, false)
+		m.write(conn, `{"event":"unsubscribe","channel":"`+topic+`"}`)
+	}
+}
+
+//火币协议
+//连接后服务器发送ping, 订阅返回订阅成功或者失败
+func (m *mockExchange) huobi(conn *websocket.Conn, msg []byte) {
+	req := &struct {
+		Id    string `json:"id"`
+		Sub   string `json:"sub"`
+		Unsub string `json:"unsub"`
+	}{}
+	if json.Unmarshal(msg, req) != nil {
+		return
+	}
+
+	switch {
+	case req.Sub != "" && m.rejected(req.Sub):
+		m.write(conn, `{"id":"`+req.Id+`","status":"error","err-code":"bad-request","err-msg":"invalid topic `+req.Sub+`"}`)
+	case req.Sub != "":
+		m.subscribe(conn, req.Sub, true)
+		m.subs <- req.Sub
+		m.write(conn, `{"id":"`+req.Id+`","status":"ok","subbed":"`+req.Sub+`"}`)
+	case req.Unsub != "":
+		m.subscribe(conn, req.Unsub, false)
+		m.write(conn, `{"id":"`+req.Id+`","status":"ok","unsubbed":"`+req.Unsub+`"}`)
+	}
+}
+
+//记录连接订阅的主题
+func (m *mockExchange) subscribe(conn *websocket.Conn, topic string, sub bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if topics, ok := m.conns[conn]; ok {
+		topics[topic] = sub
+	}
+}
+
+func (m *mockExchange) rejected(topic string) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.reject[topic]
+}
+
+//按交易所协议压缩后发送
+//okex使用deflate, 火币使用gzip
+func (m *mockExchange) write(conn *websocket.Conn, msg string) {
+	var buf bytes.Buffer
+	switch m.organize {
+	case OkEx:
+		w, _ := flate.NewWriter(&buf, flate.BestSpeed)
+		w.Write([]byte(msg))
+		w.Close()
+	case HuoBi:
+		w := gzip.NewWriter(&buf)
+		w.Write([]byte(msg))
+		w.Close()
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	conn.WriteMessage(websocket.BinaryMessage, buf.Bytes())
+}
+
+//推送数据到订阅了该主题的连接
+//和交易所相同, 没有订阅的连接收不到数据, 不能解析出主题时推送到所有连接
+func (m *mockExchange) push(msg string) {
+	topic := m.topic(msg)
+
+	m.lock.Lock()
+	conns := make([]*websocket.Conn, 0, len(m.conns))
+	for c, topics := range m.conns {
+		if topic == "" || topics[topic] {
+			conns = append(conns, c)
+		}
+	}
+	m.lock.Unlock()
+
+	for _, c := range conns {
+		m.write(c, msg)
+	}
+}
+
+//推送数据的主题
+//okex为table:instrument_id, 火币为ch
+func (m *mockExchange) topic(msg string) string {
+	data := &struct {
+		Table string `json:"table"`
+		Data  []struct {
+			InstrumentId string `json:"instrument_id"`
+		} `json:"data"`
+		Ch string `json:"ch"`
+	}{}
+	if json.Unmarshal([]byte(msg), data) != nil {
+		return ""
+	}
+
+	if m.organize == HuoBi {
+		return data.Ch
+	}
+	if data.Table == "" || len(data.Data) == 0 {
+		return ""
+	}
+	return data.Table + ":" + data.Data[0].InstrumentId
+}
+
+//主动断开所有连接
+func (m *mockExchange) disconnect() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for c := range m.conns {
+		c.Close()
+		delete(m.conns, c)
+	}
+}
+
+//等待收到指定主题的订阅
+func (m *mockExchange) waitSub(t *testing.T, topic string) {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case s := <-m.subs:
+			if s == topic {
+				return
+			}
+		case <-timeout:
+			t.Fatal("没有收到订阅", topic)
+		}
+	}
+}
+
+func (m *mockExchange) close() {
+	m.disconnect()
+	m.server.Close()
+}
+
+//从market pool读取指定币对的数据
+//其他测试写入的数据直接丢弃
+func waitMarketer(t *testing.T, symbol string) *Marketer {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case m := <-ReadMarketPool:
+			if m.Symbol == symbol {
+				return m
+			}
+		case <-timeout:
+			t.Fatal("没有收到行情", symbol)
+		}
+	}
+}
+
+//从market pool读取多个币对的数据, 不要求到达顺序
+//其他币对的数据直接丢弃
+func waitMarketers(t *testing.T, symbols ...string) {
+	t.Helper()
+
+	wait := make(map[string]bool, len(symbols))
+	for _, s := range symbols {
+		wait[s] = true
+	}
+
+	timeout := time.After(5 * time.Second)
+	for len(wait) > 0 {
+		select {
+		case m := <-ReadMarketPool:
+			delete(wait, m.Symbol)
+		case <-timeout:
+			t.Fatal("没有收到行情", wait)
+		}
+	}
+}
+
+//从event pool读取指定类型的事件
+//其他事件直接丢弃
+func waitEvent(t *testing.T, match func(Eventer) bool) Eventer {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e := <-ReadEventPool:
+			if match(e) {
+				return e
+			}
+		case <-timeout:
+			t.Fatal("没有收到事件")
+		}
+	}
+}
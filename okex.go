@@ -4,13 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/gorilla/websocket"
-	"log"
 	"strings"
+	"sync"
 	"time"
 )
 
-const okexUrl = "wss://real.OKEx.com:8443/ws/v3"
+var okexUrl = "wss://real.OKEx.com:8443/ws/v3"
+
+//okex rest地址
+var okexRestUrl = "https://www.okex.com"
 
 //ws连接超时时间
 //超过这个时间 服务器没有ping或者pong 将断开重连
@@ -20,6 +24,8 @@ const okexWsPingTimeout int64 = 10
 //记录okex服务器最后pong时间
 type okexHandler struct {
 	pongLastTime int64
+	books        map[string]*okexBook //全量深度频道的本地深度, key为订阅主题
+	bookLock     sync.Mutex           //保护books, 取消订阅和断线时在其他协程清理
 }
 
 //创建一个okex
@@ -30,133 +36,487 @@ func newOkEx(ctx context.Context) *Worker {
 		wsUrl: okexUrl,
 		handler: &okexHandler{
 			pongLastTime: time.Now().Unix(),
+			books:        make(map[string]*okexBook),
 		},
 		Organize:         OkEx,
-		Status:           runIng,
+		Status:           WorkerConnecting,
 		Subscribes:       make(map[string][]byte),
 		Subscribing:      make(map[string][]byte),
-		LastRunTimestamp: time.Duration(time.Now().UnixNano() / 1e6),
+		LastRunTimestamp: time.Now(),
 		WsConn:           nil,
 		List:             newList(),
+		sequencer:        newSequencer(),
 	}
 }
 
+//okex深度频道
+//5档使用depth5全量推送, 其他档位使用depth增量推送后本地合并
+func (h *okexHandler) depthChannel(s *Subscriber) string {
+	if s.DepthLevel == DefaultDepthLevel || s.DepthLevel == Depth5 {
+		return "depth5"
+	}
+
+	return "depth"
+}
+
 //对订阅数据进行格式化
-func (h *okexHandler) formatSubscribeHandle(s *Subscriber) (b []byte) {
-	switch s.MarketType {
-	case SpotMarket:
-		b = []byte(`{"op": "subscribe", "args": ["spot/depth5:` + s.Symbol + `"]}`)
-	case FuturesMarket:
-	case OptionMarket:
-	case WapMarket:
+//返回的订阅主题和okex订阅成功返回的channel一致
+func (h *okexHandler) formatSubscribeHandle(s *Subscriber) (topic string, b []byte) {
+	switch s.DataType {
+	case DepthData:
+		switch s.MarketType {
+		case SpotMarket:
+			topic = "spot/" + h.depthChannel(s) + ":" + s.Symbol
+		case FuturesMarket:
+			topic = "futures/" + h.depthChannel(s) + ":" + s.Symbol
+		case OptionMarket:
+		case WapMarket:
+			topic = "swap/" + h.depthChannel(s) + ":" + s.Symbol
+		}
+	case FundingRateData:
+		if s.MarketType == WapMarket {
+			topic = "swap/funding_rate:" + s.Symbol
+		}
+	case MarkPriceData:
+		if s.MarketType == WapMarket {
+			topic = "swap/mark_price:" + s.Symbol
+		}
+	case IndexPriceData:
+		topic = "index/ticker:" + s.Symbol
+	case BBOData:
+		switch s.MarketType {
+		case SpotMarket:
+			topic = "spot/ticker:" + s.Symbol
+		case FuturesMarket:
+			topic = "futures/ticker:" + s.Symbol
+		case WapMarket:
+			topic = "swap/ticker:" + s.Symbol
+		}
 	}
 
+	if topic != "" {
+		b = []byte(`{"op": "subscribe", "args": ["` + topic + `"]}`)
+	}
 	return
 }
 
+//对取消订阅数据进行格式化
+func (h *okexHandler) formatUnsubscribeHandle(topic string) []byte {
+	return []byte(`{"op": "unsubscribe", "args": ["` + topic + `"]}`)
+}
+
 //ping pong检测
 //超过规定时间, okex服务器没有返回pong 就断开了连接
 //满足pong后 向okex服务器发出ping请求
 func (h *okexHandler) pingPongHandle(w *Worker) {
 	for {
 		select {
+		case <-w.ctx.Done():
+			return
 		case <-time.NewTimer(time.Second * time.Duration(okexPingCheck)).C:
 			if (time.Now().Unix() - h.pongLastTime) > okexWsPingTimeout {
-				log.Printf("%s pingpong断线", OkEx)
-				w.closeRedialSub()
-			} else {
-				w.writeMessage(websocket.TextMessage, []byte("ping"))
+				logger().Warn("pingpong断线", "organize", w.Organize, "conn", w.id)
+				w.closeRedialSub(errPingTimeout)
+			} else if err := w.writeMessage(websocket.TextMessage, []byte("ping")); err != nil {
+				logger().Warn("发送ping失败", "organize", w.Organize, "conn", w.id, "err", err)
 			}
 		}
 	}
 }
 
-//对okex返回数据进行格式化
+//解压okex返回数据
 //目前只处理二进制数据, okex返回其他数据不处理
-func (h *okexHandler) formatMsgHandle(msgType int, msg []byte, w *Worker) (*Marketer, error) {
+func (h *okexHandler) decodeMsgHandle(msgType int, msg []byte) ([]byte, error) {
 	switch msgType {
 	case websocket.BinaryMessage:
-		msg, err := decode(msg)
-		if err != nil {
-			return nil, err
-		}
-
-		market, err := h.marketerMsg(msg)
-		if err == nil {
-			return market, err
-		}
-
-		h.pongMsg(msg)
-		h.subscribed(msg, w)
-		return nil, nil
+		return decode(msg)
 	default:
 		return nil, nil
 	}
 }
 
+//对okex返回数据进行格式化
+func (h *okexHandler) formatMsgHandle(msg []byte, w *Worker) (Eventer, error) {
+	if h.pongMsg(msg) {
+		return nil, nil
+	}
+
+	event, err := h.tableMsg(msg)
+	if err != errNotPush {
+		return event, err
+	}
+
+	h.subscribed(msg, w)
+	return nil, nil
+}
+
 //okex josn结构体
 type okexProvider struct {
-	Table string `json:"table"` //订阅类型和深度
-	Data  []struct {
-		Asks         Depth     `json:"asks"`          //卖方深度
-		Bids         Depth     `json:"bids"`          //买方深度
-		InstrumentId string    `json:"instrument_id"` //合约或者币对
-		Timestamp    time.Time `json:"timestamp"`     //数据时间戳(毫秒)
-	} `json:"data"`
+	Table string      `json:"table"` //订阅类型和深度
+	Data  []okexDepth `json:"data"`
+}
+
+//okex深度数据结构体
+type okexDepth struct {
+	Asks         Depth     `json:"asks"`          //卖方深度
+	Bids         Depth     `json:"bids"`          //买方深度
+	InstrumentId string    `json:"instrument_id"` //合约或者币对
+	Timestamp    time.Time `json:"timestamp"`     //数据时间戳(毫秒)
+}
+
+//okex table数据结构体
+//用于判断推送的数据类型
+type okexTable struct {
+	Table string `json:"table"`
 }
 
 //解析json数据
-//判断是否是深度数据
+//根据table分发到不同的数据类型
+func (h *okexHandler) tableMsg(msg []byte) (Eventer, error) {
+	table := &okexTable{}
+	err := json.Unmarshal(msg, table)
+	if err != nil {
+		return nil, err
+	}
+	if table.Table == "" {
+		return nil, errNotPush
+	}
+
+	//okex重连以后, 不会主动pong
+	h.pongLastTime = time.Now().Unix()
+
+	switch {
+	case strings.HasSuffix(table.Table, "/depth5"):
+		return h.marketerMsg(msg)
+	case strings.HasSuffix(table.Table, "/depth"):
+		return h.bookMsg(msg)
+	case table.Table == "swap/funding_rate":
+		return h.fundingRateMsg(msg)
+	case table.Table == "swap/mark_price":
+		return h.markPriceMsg(msg)
+	case table.Table == "index/ticker":
+		return h.indexPriceMsg(msg)
+	case strings.HasSuffix(table.Table, "/ticker"):
+		return h.bboMsg(msg)
+	}
+
+	return nil, nil
+}
+
+//解析深度数据
 func (h *okexHandler) marketerMsg(msg []byte) (*Marketer, error) {
 	okexData := &okexProvider{}
 	err := json.Unmarshal(msg, okexData)
 	if err != nil {
 		return nil, err
 	}
-	if okexData.Table == "" {
+	if len(okexData.Data) == 0 || len(okexData.Data[0].Bids) == 0 || len(okexData.Data[0].Asks) == 0 {
 		return nil, errors.New("序列化市场深度错误")
 	}
 
-	//okex重连以后, 不会主动pong
-	h.pongLastTime = time.Now().Unix()
 	return h.newMarketer(okexData)
 }
 
 //将深度数据转换成统一的行情数据
 func (h *okexHandler) newMarketer(p *okexProvider) (*Marketer, error) {
-	timestamp := time.Duration(p.Data[0].Timestamp.UnixNano() / 1e6)
+	timestamp := p.Data[0].Timestamp
 
 	return &Marketer{
-		Organize:  OkEx,
-		Symbol:    p.Data[0].InstrumentId,
-		BuyFirst:  p.Data[0].Bids[0][0],
-		SellFirst: p.Data[0].Asks[0][0],
-		BuyDepth:  p.Data[0].Bids,
-		SellDepth: p.Data[0].Asks,
-		Timestamp: timestamp,
-		Temporize: time.Duration(time.Now().UnixNano()/1e6) - timestamp,
+		Organize:      OkEx,
+		Symbol:        p.Data[0].InstrumentId,
+		topic:         p.Table + ":" + p.Data[0].InstrumentId,
+		BuyFirst:      p.Data[0].Bids[0][0],
+		BuyFirstSize:  p.Data[0].Bids[0][1],
+		SellFirst:     p.Data[0].Asks[0][0],
+		SellFirstSize: p.Data[0].Asks[0][1],
+		BuyDepth:      p.Data[0].Bids,
+		SellDepth:     p.Data[0].Asks,
+		Timestamp:     timestamp,
 	}, nil
 }
 
 //验证是否是pong数据
-func (h *okexHandler) pongMsg(msg []byte) {
+func (h *okexHandler) pongMsg(msg []byte) bool {
 	if string(msg) == "pong" {
 		h.pongLastTime = time.Now().Unix()
+		return true
 	}
+	return false
 }
 
 //订阅消息结构体
 type okexSubscriber struct {
-	Event   string `json:"event"`
-	Channel string `json:"channel"`
+	Event     string `json:"event"`
+	Channel   string `json:"channel"`
+	Message   string `json:"message"`
+	ErrorCode int    `json:"errorCode"`
 }
 
 //验证是否是订阅成功消息
 //订阅成功后处理数据
+//订阅失败的返回不包含主题, 从错误信息中匹配订阅中的主题
 func (h *okexHandler) subscribed(msg []byte, w *Worker) {
 	subscribe := &okexSubscriber{}
-	json.Unmarshal(msg, subscribe)
-	if subscribe.Event == "subscribe" {
-		w.subscribed(strings.Split(subscribe.Channel, ":")[1])
+	if err := json.Unmarshal(msg, subscribe); err != nil {
+		logger().Warn("解析订阅返回失败", "organize", w.Organize, "conn", w.id, "err", err)
+		return
+	}
+
+	switch subscribe.Event {
+	case "subscribe":
+		w.subscribed(subscribe.Channel)
+	case "error":
+		err := fmt.Errorf("%d %s", subscribe.ErrorCode, subscribe.Message)
+		for _, topic := range w.subscribingTopics() {
+			if strings.Contains(subscribe.Message, topic) {
+				w.subscribeFailed(topic, err)
+				return
+			}
+		}
+		w.subscribeFailed("", err)
+	}
+}
+
+//okex资金费率结构体
+//推送不包含数据时间, FundingRate.Timestamp为零值
+type okexFundingRate struct {
+	Data []struct {
+		InstrumentId  string    `json:"instrument_id"`  //合约
+		FundingRate   string    `json:"funding_rate"`   //当期资金费率
+		EstimatedRate string    `json:"estimated_rate"` //预测资金费率
+		FundingTime   time.Time `json:"funding_time"`   //当期资金费率结算时间
+	} `json:"data"`
+}
+
+//解析资金费率
+func (h *okexHandler) fundingRateMsg(msg []byte) (*FundingRate, error) {
+	okexData := &okexFundingRate{}
+	err := json.Unmarshal(msg, okexData)
+	if err != nil {
+		return nil, err
+	}
+	if len(okexData.Data) == 0 {
+		return nil, errors.New("序列化资金费率错误")
 	}
+
+	d := okexData.Data[0]
+	return &FundingRate{
+		Event: Event{
+			Type:     FundingRateEvent,
+			Organize: OkEx,
+			Symbol:   d.InstrumentId,
+		},
+		FundingRate:     d.FundingRate,
+		EstimatedRate:   d.EstimatedRate,
+		NextFundingTime: d.FundingTime,
+	}, nil
+}
+
+//okex标记价格结构体
+type okexMarkPrice struct {
+	Data []struct {
+		InstrumentId string    `json:"instrument_id"` //合约
+		MarkPrice    string    `json:"mark_price"`    //标记价格
+		Timestamp    time.Time `json:"timestamp"`     //数据时间
+	} `json:"data"`
+}
+
+//解析标记价格
+func (h *okexHandler) markPriceMsg(msg []byte) (*MarkPrice, error) {
+	okexData := &okexMarkPrice{}
+	err := json.Unmarshal(msg, okexData)
+	if err != nil {
+		return nil, err
+	}
+	if len(okexData.Data) == 0 {
+		return nil, errors.New("序列化标记价格错误")
+	}
+
+	d := okexData.Data[0]
+	return &MarkPrice{
+		Event: Event{
+			Type:      MarkPriceEvent,
+			Organize:  OkEx,
+			Symbol:    d.InstrumentId,
+			Timestamp: d.Timestamp,
+		},
+		MarkPrice: d.MarkPrice,
+	}, nil
+}
+
+//okex指数结构体
+type okexIndexTicker struct {
+	Data []struct {
+		InstrumentId string    `json:"instrument_id"` //指数
+		Last         string    `json:"last"`          //最新指数价格
+		Timestamp    time.Time `json:"timestamp"`     //数据时间
+	} `json:"data"`
+}
+
+//解析指数价格
+func (h *okexHandler) indexPriceMsg(msg []byte) (*IndexPrice, error) {
+	okexData := &okexIndexTicker{}
+	err := json.Unmarshal(msg, okexData)
+	if err != nil {
+		return nil, err
+	}
+	if len(okexData.Data) == 0 {
+		return nil, errors.New("序列化指数价格错误")
+	}
+
+	d := okexData.Data[0]
+	return &IndexPrice{
+		Event: Event{
+			Type:      IndexPriceEvent,
+			Organize:  OkEx,
+			Symbol:    d.InstrumentId,
+			Timestamp: d.Timestamp,
+		},
+		IndexPrice: d.Last,
+	}, nil
+}
+
+//okex rest轮询handler
+//okex ws没有持仓量和强平订单数据
+type okexPollHandler struct{}
+
+//返回的订阅主题前缀用于区分返回数据类型
+func (h *okexPollHandler) formatPollHandle(s *Subscriber) (topic string, url string) {
+	var market string
+	switch s.MarketType {
+	case FuturesMarket:
+		market = "futures"
+	case WapMarket:
+		market = "swap"
+	default:
+		return
+	}
+
+	switch s.DataType {
+	case OpenInterestData:
+		topic = market + "/open_interest:" + s.Symbol
+		url = okexRestUrl + "/api/" + market + "/v3/instruments/" + s.Symbol + "/open_interest"
+	case LiquidationData:
+		topic = market + "/liquidation:" + s.Symbol
+		url = okexRestUrl + "/api/" + market + "/v3/instruments/" + s.Symbol + "/liquidation?status=0"
+	}
+
+	return
+}
+
+func (h *okexPollHandler) formatPollMsg(topic string, body []byte) ([]Eventer, error) {
+	if strings.Contains(topic, "/open_interest:") {
+		return h.openInterestMsg(body)
+	}
+
+	return h.liquidationMsg(body)
+}
+
+//okex持仓量结构体
+type okexOpenInterest struct {
+	InstrumentId string    `json:"instrument_id"` //合约
+	Amount       string    `json:"amount"`        //持仓量(张)
+	Timestamp    time.Time `json:"timestamp"`     //数据时间
+}
+
+//解析持仓量
+func (h *okexPollHandler) openInterestMsg(body []byte) ([]Eventer, error) {
+	okexData := &okexOpenInterest{}
+	err := json.Unmarshal(body, okexData)
+	if err != nil {
+		return nil, err
+	}
+	if okexData.InstrumentId == "" {
+		return nil, errors.New("序列化持仓量错误")
+	}
+
+	return []Eventer{&OpenInterest{
+		Event: Event{
+			Type:      OpenInterestEvent,
+			Organize:  OkEx,
+			Symbol:    okexData.InstrumentId,
+			Timestamp: okexData.Timestamp,
+		},
+		Volume: okexData.Amount,
+	}}, nil
+}
+
+//okex强平订单结构体
+//type 3:强平多 4:强平空
+type okexLiquidation struct {
+	InstrumentId string    `json:"instrument_id"` //合约
+	Type         string    `json:"type"`          //强平类型
+	Price        string    `json:"price"`         //强平价格
+	Size         string    `json:"size"`          //强平数量(张)
+	CreatedAt    time.Time `json:"created_at"`    //强平时间
+}
+
+//解析强平订单
+//强平多对应卖出订单, 强平空对应买入订单
+func (h *okexPollHandler) liquidationMsg(body []byte) ([]Eventer, error) {
+	var okexData []okexLiquidation
+	err := json.Unmarshal(body, &okexData)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]Eventer, 0, len(okexData))
+	for _, d := range okexData {
+		side := "buy"
+		if d.Type == "3" {
+			side = "sell"
+		}
+
+		events = append(events, &Liquidation{
+			Event: Event{
+				Type:      LiquidationEvent,
+				Organize:  OkEx,
+				Symbol:    d.InstrumentId,
+				Timestamp: d.CreatedAt,
+			},
+			Side:  side,
+			Price: d.Price,
+			Size:  d.Size,
+		})
+	}
+	return events, nil
+}
+
+//okex ticker结构体
+//只解析最优买卖价
+type okexTicker struct {
+	Data []struct {
+		InstrumentId string    `json:"instrument_id"` //合约或者币对
+		BestBid      string    `json:"best_bid"`      //买一价格
+		BestBidSize  string    `json:"best_bid_size"` //买一数量
+		BestAsk      string    `json:"best_ask"`      //卖一价格
+		BestAskSize  string    `json:"best_ask_size"` //卖一数量
+		Timestamp    time.Time `json:"timestamp"`     //数据时间
+	} `json:"data"`
+}
+
+//解析最优买卖价
+func (h *okexHandler) bboMsg(msg []byte) (*BBO, error) {
+	okexData := &okexTicker{}
+	err := json.Unmarshal(msg, okexData)
+	if err != nil {
+		return nil, err
+	}
+	if len(okexData.Data) == 0 {
+		return nil, errors.New("序列化最优买卖价错误")
+	}
+
+	d := okexData.Data[0]
+	return &BBO{
+		Event: Event{
+			Type:      BBOEvent,
+			Organize:  OkEx,
+			Symbol:    d.InstrumentId,
+			Timestamp: d.Timestamp,
+		},
+		BidPrice: d.BestBid,
+		BidSize:  d.BestBidSize,
+		AskPrice: d.BestAsk,
+		AskSize:  d.BestAskSize,
+	}, nil
 }
@@ -0,0 +1,183 @@
+package market
+
+import (
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//okex校验和使用的档位
+const okexChecksumLevel = 25
+
+//okex本地深度
+//全量深度频道先推送partial, 之后推送增量update
+type okexBook struct {
+	bids map[string]string //买方深度, 价格:数量
+	asks map[string]string //卖方深度, 价格:数量
+}
+
+func newOkexBook() *okexBook {
+	return &okexBook{
+		bids: make(map[string]string),
+		asks: make(map[string]string),
+	}
+}
+
+//合并增量数据
+//数量为0时删除该价格
+func (b *okexBook) update(side map[string]string, d Depth) {
+	for _, v := range d {
+		if size, _ := strconv.ParseFloat(v[1], 64); size == 0 {
+			delete(side, v[0])
+		} else {
+			side[v[0]] = v[1]
+		}
+	}
+}
+
+//按价格排序深度
+//desc为true时从高到低
+func (b *okexBook) sorted(side map[string]string, desc bool) Depth {
+	prices := make([]float64, 0, len(side))
+	keys := make(map[float64]string, len(side))
+	for k := range side {
+		p, _ := strconv.ParseFloat(k, 64)
+		prices = append(prices, p)
+		keys[p] = k
+	}
+
+	if desc {
+		sort.Sort(sort.Reverse(sort.Float64Slice(prices)))
+	} else {
+		sort.Float64s(prices)
+	}
+
+	d := make(Depth, len(prices))
+	for i, p := range prices {
+		d[i] = [2]string{keys[p], side[keys[p]]}
+	}
+	return d
+}
+
+//计算okex校验和
+//前25档买卖深度交替拼接后crc32
+func (b *okexBook) checksum(bids, asks Depth) int32 {
+	var s []string
+	for i := 0; i < okexChecksumLevel; i++ {
+		if i < len(bids) {
+			s = append(s, bids[i][0], bids[i][1])
+		}
+		if i < len(asks) {
+			s = append(s, asks[i][0], asks[i][1])
+		}
+	}
+
+	return int32(crc32.ChecksumIEEE([]byte(strings.Join(s, ":"))))
+}
+
+//okex全量深度结构体
+type okexBookProvider struct {
+	Table  string         `json:"table"`
+	Action string         `json:"action"` //partial:全量 update:增量
+	Data   []okexBookData `json:"data"`
+}
+
+//okex全量深度数据结构体
+type okexBookData struct {
+	okexDepth
+	Checksum int32 `json:"checksum"` //前25档校验和
+}
+
+//okex增量深度
+//需要按读取顺序合并到本地深度
+type okexBookUpdate struct {
+	Event
+	h *okexHandler
+	p *okexBookProvider
+}
+
+//解析全量深度
+//只做反序列化, 合并在merge中按顺序执行
+func (h *okexHandler) bookMsg(msg []byte) (*okexBookUpdate, error) {
+	okexData := &okexBookProvider{}
+	err := json.Unmarshal(msg, okexData)
+	if err != nil {
+		return nil, err
+	}
+	if len(okexData.Data) == 0 {
+		return nil, errors.New("序列化市场深度错误")
+	}
+
+	return &okexBookUpdate{
+		Event: Event{
+			Type:     DepthEvent,
+			Organize: OkEx,
+			Symbol:   okexData.Data[0].InstrumentId,
+		},
+		h: h,
+		p: okexData,
+	}, nil
+}
+
+//取消订阅后删除主题的本地深度
+func (h *okexHandler) resetTopic(topic string) {
+	h.bookLock.Lock()
+	defer h.bookLock.Unlock()
+
+	delete(h.books, topic)
+}
+
+//断线后删除所有本地深度
+//重新订阅后等待新的全量深度
+func (h *okexHandler) resetTopics() {
+	h.bookLock.Lock()
+	defer h.bookLock.Unlock()
+
+	h.books = make(map[string]*okexBook)
+}
+
+//合并增量深度到本地深度
+//校验和错误时删除本地深度, 重新订阅
+func (u *okexBookUpdate) merge(w *Worker) (Eventer, error) {
+	d := u.p.Data[0]
+	topic := u.p.Table + ":" + d.InstrumentId
+	u.h.bookLock.Lock()
+	defer u.h.bookLock.Unlock()
+	books := u.h.books
+
+	book, ok := books[topic]
+	if u.p.Action == "partial" {
+		book = newOkexBook()
+		books[topic] = book
+	} else if !ok {
+		return nil, errors.New(topic + " 没有收到全量深度")
+	}
+
+	book.update(book.bids, d.Bids)
+	book.update(book.asks, d.Asks)
+	bids := book.sorted(book.bids, true)
+	asks := book.sorted(book.asks, false)
+
+	if book.checksum(bids, asks) != d.Checksum {
+		delete(books, topic)
+		w.Subscribe(u.h.formatUnsubscribeHandle(topic))
+		w.Subscribe([]byte(`{"op": "subscribe", "args": ["` + topic + `"]}`))
+		return nil, errors.New(topic + " 深度校验和错误")
+	}
+	if len(bids) == 0 || len(asks) == 0 {
+		return nil, errors.New("序列化市场深度错误")
+	}
+
+	return u.h.newMarketer(&okexProvider{
+		Table: u.p.Table,
+		Data: []okexDepth{{
+			Asks:         asks,
+			Bids:         bids,
+			InstrumentId: d.InstrumentId,
+			Timestamp:    d.Timestamp,
+		}},
+	})
+}
@@ -0,0 +1,59 @@
+package market
+
+import (
+	"context"
+	"testing"
+)
+
+func TestOkexBookUpdate_Merge(t *testing.T) {
+	h := &okexHandler{books: make(map[string]*okexBook)}
+	w := &Worker{}
+
+	//okex文档的校验和示例, 拼接后为3366.1:7:3366.8:9:3366:6:3368:8
+	u, err := h.bookMsg([]byte(`{"table":"spot/depth_l2_tbt","action":"partial","data":[{"instrument_id":"BTC-USDT",` +
+		`"asks":[["3366.8","9","10","3"],["3368","8","3","4"]],"bids":[["3366.1","7","0","3"],["3366","6","3","4"]],` +
+		`"timestamp":"2020-03-01T08:00:00.123Z","checksum":-1881014294}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.merge(w); err != nil {
+		t.Fatal(err)
+	}
+
+	//删除3366.1, 新增3366.5, 拼接后为3366.5:2:3366.8:9:3366:6:3368:8
+	u, err = h.bookMsg([]byte(`{"table":"spot/depth_l2_tbt","action":"update","data":[{"instrument_id":"BTC-USDT",` +
+		`"asks":[],"bids":[["3366.1","0","0","0"],["3366.5","2","0","1"]],` +
+		`"timestamp":"2020-03-01T08:00:00.223Z","checksum":1297519180}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := u.merge(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := m.(*Marketer); e.BuyFirst != "3366.5" || e.BuyFirstSize != "2" || len(e.BuyDepth) != 2 || e.SellFirst != "3366.8" {
+		t.Fatal(e)
+	}
+}
+
+func TestOkexHandler_ResetTopic(t *testing.T) {
+	g := newWorkerGroup(context.Background(), newOkEx)
+	s := &Subscriber{Symbol: "BTC-USDT", Organize: OkEx, MarketType: SpotMarket, DepthLevel: Depth20}
+	g.subscribeHandle(s)
+	w := g.workers[0]
+	h := w.handler.(*okexHandler)
+	h.books["spot/depth:BTC-USDT"] = newOkexBook()
+	h.books["spot/depth:ETH-USDT"] = newOkexBook()
+
+	//取消订阅删除该主题的本地深度
+	g.unsubscribeHandle(s)
+	if _, ok := h.books["spot/depth:BTC-USDT"]; ok || len(h.books) != 1 {
+		t.Fatal(h.books)
+	}
+
+	//断线删除所有本地深度
+	h.resetTopics()
+	if len(h.books) != 0 {
+		t.Fatal(h.books)
+	}
+}
@@ -0,0 +1,50 @@
+package market
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOkexHandler_FundingRateMsg(t *testing.T) {
+	h := &okexHandler{}
+
+	f, err := h.fundingRateMsg([]byte(`{"table":"swap/funding_rate","data":[{"estimated_rate":"0.0002","funding_rate":"0.0001","funding_time":"2020-03-01T08:00:00.000Z","instrument_id":"BTC-USD-SWAP","interest_rate":"0"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	//推送不包含数据时间, 不使用本地时间
+	if f.Symbol != "BTC-USD-SWAP" || f.FundingRate != "0.0001" || f.EstimatedRate != "0.0002" || !f.Timestamp.IsZero() ||
+		!f.NextFundingTime.Equal(time.Date(2020, 3, 1, 8, 0, 0, 0, time.UTC)) {
+		t.Fatal(f)
+	}
+
+	if _, err := h.fundingRateMsg([]byte(`{"table":"swap/funding_rate","data":[]}`)); err == nil {
+		t.Fatal("没有返回错误")
+	}
+}
+
+func TestOkexHandler_BboMsg(t *testing.T) {
+	h := &okexHandler{}
+
+	e, err := h.bboMsg([]byte(`{"table":"spot/ticker","data":[{"instrument_id":"ETH-USDT","last":"8.8","best_bid":"8.75","best_bid_size":"1.2","best_ask":"8.8","best_ask_size":"3.4","timestamp":"2020-03-01T08:00:00.123Z"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Type != BBOEvent || e.Organize != OkEx || e.Symbol != "ETH-USDT" || e.BidPrice != "8.75" || e.BidSize != "1.2" ||
+		e.AskPrice != "8.8" || e.AskSize != "3.4" || millisecond(e.Timestamp) != 1583049600123 {
+		t.Fatal(e)
+	}
+
+	//深度行情的买一卖一数量
+	m, err := h.marketerMsg([]byte(`{"table":"spot/depth5","data":[{"asks":[["8.8","3","0","1"]],"bids":[["8.75","1","0","2"]],"instrument_id":"ETH-USDT","timestamp":"2020-03-01T08:00:00.123Z"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.BuyFirst != "8.75" || m.BuyFirstSize != "1" || m.SellFirst != "8.8" || m.SellFirstSize != "3" {
+		t.Fatal(m)
+	}
+
+	if _, err := h.bboMsg([]byte(`{"table":"spot/ticker","data":[]}`)); err == nil {
+		t.Fatal("没有返回错误")
+	}
+}
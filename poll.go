@@ -0,0 +1,200 @@
+package market
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//rest轮询间隔(秒)
+const pollInterval = 3
+
+//连续失败超过这个轮数视为轮询不可用
+const pollMaxFailures = 3
+
+type (
+
+	//各个交易所rest轮询handle接口
+	//交易所ws没有提供的数据使用rest轮询
+	pollHandler interface {
+		formatPollHandle(*Subscriber) (string, string)              //格式化订阅消息, 返回订阅主题和请求地址
+		formatPollMsg(topic string, body []byte) ([]Eventer, error) //处理rest返回数据
+	}
+
+	//rest轮询基础
+	poller struct {
+		ctx      context.Context
+		Organize Organize
+		client   *http.Client
+		handler  pollHandler
+		topics   map[string]string    //订阅中的请求地址, key为订阅主题
+		last     map[string]time.Time //每个主题最后推送的数据时间
+		refs     map[string]int       //每个主题的订阅次数
+		polled   time.Time            //最后一轮全部主题请求成功的时间
+		failures int                  //连续失败的轮数
+		lastErr  error                //最后一次请求失败的错误
+		seqs     *sequences           //每个数据流的序号
+		lock     sync.Mutex
+	}
+)
+
+func newPoller(ctx context.Context, organize Organize, handler pollHandler) *poller {
+	return &poller{
+		ctx:      ctx,
+		Organize: organize,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		handler:  handler,
+		topics:   make(map[string]string),
+		last:     make(map[string]time.Time),
+		refs:     make(map[string]int),
+		seqs:     newSequences(),
+	}
+}
+
+//根据订阅找到对应的poller
+//不需要轮询的订阅返回nil
+func pollRoute(s *Subscriber) *poller {
+	switch s.DataType {
+	case OpenInterestData:
+		return Manage.polls[s.Organize]
+	case LiquidationData:
+		if s.Organize == OkEx {
+			return Manage.polls[s.Organize]
+		}
+	}
+
+	return nil
+}
+
+//处理订阅数据格式
+//每次订阅增加主题的引用计数, 交易所不支持的订阅直接忽略
+func (p *poller) subscribeHandle(s *Subscriber) {
+	topic, url := p.handler.formatPollHandle(s)
+	if topic == "" {
+		logger().Warn("不支持的订阅", "organize", p.Organize, "symbol", s.Symbol, "data_type", s.DataType)
+		return
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.refs[topic]++
+	p.topics[topic] = url
+	if _, ok := p.last[topic]; !ok {
+		p.last[topic] = time.Now()
+	}
+}
+
+//取消轮询
+//最后一个订阅取消时停止轮询该主题
+func (p *poller) unsubscribeHandle(s *Subscriber) {
+	topic, _ := p.handler.formatPollHandle(s)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.refs[topic]--; p.refs[topic] > 0 {
+		return
+	}
+	delete(p.refs, topic)
+	delete(p.topics, topic)
+	delete(p.last, topic)
+}
+
+//运行轮询
+//直到context关闭
+func (p *poller) RunTask() {
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-time.NewTimer(pollInterval * time.Second).C:
+			p.pollAll()
+		}
+	}
+}
+
+//请求一轮所有订阅的主题
+//任意主题失败时记为一次失败
+func (p *poller) pollAll() {
+	p.lock.Lock()
+	topics := make(map[string]string, len(p.topics))
+	for k, v := range p.topics {
+		topics[k] = v
+	}
+	p.lock.Unlock()
+	if len(topics) == 0 {
+		return
+	}
+
+	var failed error
+	for topic, url := range topics {
+		if err := p.poll(topic, url); err != nil {
+			logger().Warn("轮询失败", "organize", p.Organize, "topic", topic, "err", err)
+			failed = err
+		}
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if failed != nil {
+		p.failures++
+		p.lastErr = failed
+		return
+	}
+	p.failures = 0
+	p.polled = time.Now()
+}
+
+//请求一次数据
+//只推送订阅以后并且比上次更新的数据
+func (p *poller) poll(topic, url string) error {
+	body, err := p.get(url)
+	if err != nil {
+		return err
+	}
+
+	events, err := p.handler.formatPollMsg(topic, body)
+	if err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	last := p.last[topic]
+	for _, e := range events {
+		ts := e.Base().Timestamp
+		if ts.After(last) && p.seqs.next(e, nil) {
+			liveOutput.event(e)
+		}
+		if ts.After(p.last[topic]) {
+			p.last[topic] = ts
+		}
+	}
+	return nil
+}
+
+func (p *poller) get(url string) ([]byte, error) {
+	return httpGet(p.ctx, p.client, url)
+}
+
+//get请求, 返回状态码不是200时返回错误
+func httpGet(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
@@ -0,0 +1,165 @@
+package market
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOkexPollHandler_FormatPollMsg(t *testing.T) {
+	h := &okexPollHandler{}
+
+	events, err := h.formatPollMsg("swap/open_interest:BTC-USD-SWAP", []byte(`{"instrument_id":"BTC-USD-SWAP","amount":"1234","timestamp":"2020-03-01T08:00:00.000Z"}`))
+	if err != nil || len(events) != 1 {
+		t.Fatal(events, err)
+	}
+	oi := events[0].(*OpenInterest)
+	if oi.Type != OpenInterestEvent || oi.Organize != OkEx || oi.Symbol != "BTC-USD-SWAP" || oi.Volume != "1234" ||
+		!oi.Timestamp.Equal(time.Date(2020, 3, 1, 8, 0, 0, 0, time.UTC)) {
+		t.Fatal(oi)
+	}
+
+	events, err = h.formatPollMsg("swap/liquidation:BTC-USD-SWAP", []byte(`[
+		{"loss":"0","size":"2","price":"9000.5","created_at":"2020-03-01T08:00:01.000Z","instrument_id":"BTC-USD-SWAP","type":"3"},
+		{"loss":"0","size":"5","price":"9010","created_at":"2020-03-01T08:00:02.000Z","instrument_id":"BTC-USD-SWAP","type":"4"}
+	]`))
+	if err != nil || len(events) != 2 {
+		t.Fatal(events, err)
+	}
+	long, short := events[0].(*Liquidation), events[1].(*Liquidation)
+	if long.Type != LiquidationEvent || long.Side != "sell" || long.Price != "9000.5" || long.Size != "2" ||
+		!long.Timestamp.Equal(time.Date(2020, 3, 1, 8, 0, 1, 0, time.UTC)) || short.Side != "buy" || short.Size != "5" {
+		t.Fatal(long, short)
+	}
+
+	if _, err := h.formatPollMsg("swap/open_interest:BTC-USD-SWAP", []byte(`{"code":30032}`)); err == nil {
+		t.Fatal("没有返回错误")
+	}
+}
+
+func TestHuoBiPollHandler_FormatPollMsg(t *testing.T) {
+	h := &huoBiPollHandler{}
+
+	events, err := h.formatPollMsg("swap_open_interest.BTC-USD", []byte(`{"status":"ok","data":[{"volume":1000,"amount":10.5,"symbol":"BTC","contract_code":"BTC-USD"}],"ts":1583049600000}`))
+	if err != nil || len(events) != 1 {
+		t.Fatal(events, err)
+	}
+	oi := events[0].(*OpenInterest)
+	if oi.Organize != HuoBi || oi.Symbol != "BTC-USD" || oi.Volume != "1000" || oi.Amount != "10.5" || millisecond(oi.Timestamp) != 1583049600000 {
+		t.Fatal(oi)
+	}
+
+	if _, err := h.formatPollMsg("swap_open_interest.BTC-USD", []byte(`{"status":"error","err_code":1014}`)); err == nil {
+		t.Fatal("没有返回错误")
+	}
+}
+
+func TestPoller_Poll(t *testing.T) {
+	body := `[
+		{"size":"1","price":"9000","created_at":"2020-03-01T08:00:01.000Z","instrument_id":"POLL-USD-SWAP","type":"3"},
+		{"size":"2","price":"9001","created_at":"2020-03-01T08:00:02.000Z","instrument_id":"POLL-USD-SWAP","type":"3"},
+		{"size":"3","price":"9002","created_at":"2020-03-01T08:00:03.000Z","instrument_id":"POLL-USD-SWAP","type":"4"}
+	]`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	sink := &memorySink{}
+	pub := NewPublisher(sink, SinkOptions{FlushInterval: 5 * time.Millisecond})
+	SetPublisher(pub)
+	defer SetPublisher(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go pub.Run(ctx)
+
+	p := newPoller(ctx, OkEx, &okexPollHandler{})
+	topic := "swap/liquidation:POLL-USD-SWAP"
+	p.topics[topic] = server.URL
+	p.last[topic] = time.Date(2020, 3, 1, 8, 0, 1, 0, time.UTC)
+
+	//只推送比上次更新的数据, 再次请求相同的数据不重复推送
+	for i := 0; i < 2; i++ {
+		if err := p.poll(topic, server.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for deadline := time.Now().Add(time.Second); sink.count() < 2 && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	sink.lock.Lock()
+	var sizes []string
+	for _, batch := range sink.batches {
+		for _, msg := range batch {
+			if msg.Type != LiquidationEvent || msg.Symbol != "POLL-USD-SWAP" {
+				t.Fatal(msg)
+			}
+			sizes = append(sizes, string(msg.Value))
+		}
+	}
+	sink.lock.Unlock()
+	if len(sizes) != 2 || !p.last[topic].Equal(time.Date(2020, 3, 1, 8, 0, 3, 0, time.UTC)) {
+		t.Fatal(sizes, p.last[topic])
+	}
+
+	e := waitEvent(t, func(e Eventer) bool { return e.Base().Symbol == "POLL-USD-SWAP" }).(*Liquidation)
+	if e.Size != "2" || e.Seq != 1 {
+		t.Fatal(e)
+	}
+}
+
+func TestPoller_Status(t *testing.T) {
+	fail := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	p := newPoller(context.Background(), OkEx, &okexPollHandler{})
+	if s := p.status(); !s.healthy() {
+		t.Fatal(s)
+	}
+
+	p.topics["swap/liquidation:POLL-USD-SWAP"] = server.URL
+	for i := 0; i < pollMaxFailures; i++ {
+		p.pollAll()
+	}
+	if s := p.status(); s.healthy() || s.Failures != pollMaxFailures || s.LastError == "" || len(s.Topics) != 1 {
+		t.Fatal(s)
+	}
+
+	fail = false
+	p.pollAll()
+	if s := p.status(); !s.healthy() || s.Failures != 0 || s.LastPoll.IsZero() {
+		t.Fatal(s)
+	}
+}
+
+func TestHuoBiNotifyHandler_LiquidationMsg(t *testing.T) {
+	h := &huoBiNotifyHandler{}
+
+	batch, err := h.liquidationMsg(&huobiNotifyMsg{
+		Op:    "notify",
+		Topic: "public.BTC-USD.liquidation_orders",
+		Data:  []byte(`[{"symbol":"BTC","contract_code":"BTC-USD","direction":"sell","offset":"close","volume":173,"price":9000.3,"created_at":1583049600123}]`),
+	})
+	if err != nil || len(batch) != 1 {
+		t.Fatal(batch, err)
+	}
+	l := batch[0].(*Liquidation)
+	if l.Organize != HuoBi || l.Symbol != "BTC-USD" || l.Side != "sell" || l.Size != "173" || l.Price != "9000.3" || millisecond(l.Timestamp) != 1583049600123 {
+		t.Fatal(l)
+	}
+
+	if _, err := h.liquidationMsg(&huobiNotifyMsg{Data: []byte(`[]`)}); err == nil {
+		t.Fatal("没有返回错误")
+	}
+}
@@ -0,0 +1,439 @@
+package market
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+//记录文件格式
+type RecordFormat string
+
+//json lines, 每行一个Frame
+const RecordJson RecordFormat = "jsonl"
+
+//长度前缀二进制
+//每条记录为 4字节长度 + 8字节接收时间(unix纳秒) + 4字节连接编号 + 1字节交易所长度 + 交易所 + [8字节时钟偏移(纳秒)] + 数据, 整数为大端序
+//长度不包含自身的4字节, 交易所长度的最高位为1时包含时钟偏移, 之前的记录没有时钟偏移
+const RecordBinary RecordFormat = "bin"
+
+//二进制深度行情
+//记录解析后的深度行情而不是原始数据, 不能用于回放
+//每条记录为 4字节长度(大端序) + Codec编码的Marketer
+const RecordMarketer RecordFormat = "mkt"
+
+//记录文件压缩方式
+type RecordCompress string
+
+//不压缩
+const RecordNone RecordCompress = ""
+
+//gzip压缩
+const RecordGzip RecordCompress = "gz"
+
+//zstd压缩
+const RecordZstd RecordCompress = "zst"
+
+//记录配置
+type RecorderOptions struct {
+	Dir      string         //记录文件目录
+	Format   RecordFormat   //文件格式, 默认json lines
+	Compress RecordCompress //压缩方式, 默认不压缩
+	MaxSize  int64          //单个文件最大字节数(压缩前), 超过后切换新文件, 0不限制
+	MaxAge   time.Duration  //单个文件最长记录时间, 超过后切换新文件, 0不限制
+}
+
+//一条原始ws数据
+type Frame struct {
+	Received time.Time     `json:"received"`         //本地接收时间
+	Organize Organize      `json:"organize"`         //连接的交易所
+	Conn     int           `json:"conn"`             //连接编号
+	Offset   time.Duration `json:"offset,omitempty"` //接收时的交易所时钟偏移, 回放时用于计算Latency
+	Data     string        `json:"data"`             //解压后的ws数据
+}
+
+//原始数据记录
+//记录每个连接收到的解压后数据, 按大小和时间切换文件
+type Recorder struct {
+	opts    RecorderOptions
+	file    *os.File
+	writer  *bufio.Writer
+	encoder io.WriteCloser //压缩写入, 不压缩时为nil
+	size    int64          //当前文件已写入的字节数(压缩前)
+	opened  time.Time      //当前文件创建时间
+	buf     []byte
+	codec   *Codec //RecordMarketer使用
+	lock    sync.Mutex
+}
+
+//创建原始数据记录
+//目录不存在时创建目录
+func NewRecorder(opts RecorderOptions) (*Recorder, error) {
+	if opts.Format == "" {
+		opts.Format = RecordJson
+	}
+	if opts.Format != RecordJson && opts.Format != RecordBinary && opts.Format != RecordMarketer {
+		return nil, errors.New("不支持的记录格式 " + string(opts.Format))
+	}
+	if opts.Compress != RecordNone && opts.Compress != RecordGzip && opts.Compress != RecordZstd {
+		return nil, errors.New("不支持的压缩方式 " + string(opts.Compress))
+	}
+
+	err := os.MkdirAll(opts.Dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Recorder{opts: opts, codec: NewCodec()}, nil
+}
+
+//设置原始数据记录
+//nil停止记录, 停止后需要调用Close关闭文件
+func SetRecorder(r *Recorder) {
+	Manage.record.Store(r)
+}
+
+//当前使用的记录
+func recorder() *Recorder {
+	r, _ := Manage.record.Load().(*Recorder)
+	return r
+}
+
+//记录一条数据
+//写入失败只记录日志, 不影响行情处理
+func (r *Recorder) record(w *Worker, received time.Time, offset time.Duration, msg []byte) {
+	if r == nil || r.opts.Format == RecordMarketer {
+		return
+	}
+
+	err := r.Write(&Frame{
+		Received: received,
+		Organize: w.Organize,
+		Conn:     w.id,
+		Offset:   offset,
+		Data:     string(msg),
+	})
+	if err != nil {
+		logger().Warn("记录原始数据失败", "organize", w.Organize, "conn", w.id, "err", err)
+	}
+}
+
+//写入一条数据
+func (r *Recorder) Write(f *Frame) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	err := r.rotate(f.Received)
+	if err != nil {
+		return err
+	}
+
+	r.buf = r.buf[:0]
+	switch r.opts.Format {
+	case RecordBinary:
+		r.buf = appendBinaryFrame(r.buf, f)
+	default:
+		j, err := json.Marshal(f)
+		if err != nil {
+			return err
+		}
+		r.buf = append(append(r.buf, j...), '\n')
+	}
+
+	n, err := r.writer.Write(r.buf)
+	r.size += int64(n)
+	return err
+}
+
+//记录一条深度行情
+//只有RecordMarketer格式记录, 写入失败只记录日志
+func (r *Recorder) save(data Eventer) {
+	if r == nil || r.opts.Format != RecordMarketer {
+		return
+	}
+
+	m, ok := data.(*Marketer)
+	if !ok {
+		return
+	}
+	if err := r.WriteMarketer(m); err != nil {
+		logger().Warn("记录深度行情失败", "organize", m.Organize, "symbol", m.Symbol, "err", err)
+	}
+}
+
+//写入一条深度行情
+//按本地接收时间切换文件, 没有接收时间时使用当前时间
+func (r *Recorder) WriteMarketer(m *Marketer) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := m.ReceivedAt
+	if now.IsZero() {
+		now = time.Now()
+	}
+	err := r.rotate(now)
+	if err != nil {
+		return err
+	}
+
+	r.buf, err = r.codec.AppendMarketer(append(r.buf[:0], 0, 0, 0, 0), m)
+	if err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint32(r.buf, uint32(len(r.buf)-4))
+
+	n, err := r.writer.Write(r.buf)
+	r.size += int64(n)
+	return err
+}
+
+//需要时切换新文件
+//第一次写入时创建文件
+func (r *Recorder) rotate(now time.Time) error {
+	if r.file != nil {
+		full := r.opts.MaxSize > 0 && r.size >= r.opts.MaxSize
+		expired := r.opts.MaxAge > 0 && now.Sub(r.opened) >= r.opts.MaxAge
+		if !full && !expired {
+			return nil
+		}
+
+		err := r.closeFile()
+		if err != nil {
+			return err
+		}
+	}
+
+	name := "market-" + now.UTC().Format("20060102T150405.000000000") + "." + string(r.opts.Format)
+	if r.opts.Compress != RecordNone {
+		name += "." + string(r.opts.Compress)
+	}
+	file, err := os.OpenFile(filepath.Join(r.opts.Dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = file
+	switch r.opts.Compress {
+	case RecordGzip:
+		r.encoder = gzip.NewWriter(file)
+		out = r.encoder
+	case RecordZstd:
+		enc, err := zstd.NewWriter(file)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		r.encoder = enc
+		out = enc
+	}
+
+	r.file = file
+	r.writer = bufio.NewWriter(out)
+	r.size = 0
+	r.opened = now
+	return nil
+}
+
+//写入缓存并关闭当前文件
+func (r *Recorder) closeFile() error {
+	if r.file == nil {
+		return nil
+	}
+
+	err := r.writer.Flush()
+	if r.encoder != nil {
+		if e := r.encoder.Close(); err == nil {
+			err = e
+		}
+		r.encoder = nil
+	}
+	if e := r.file.Close(); err == nil {
+		err = e
+	}
+	r.file = nil
+	return err
+}
+
+//写入缓存到文件
+//压缩文件只写入到压缩器, 关闭后才是完整的压缩文件
+func (r *Recorder) Flush() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	return r.writer.Flush()
+}
+
+//关闭当前文件
+//之后写入时创建新文件
+func (r *Recorder) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.closeFile()
+}
+
+//二进制记录包含时钟偏移的标记, 和交易所长度一起写入
+const recordOffsetFlag = 0x80
+
+//追加一条二进制记录
+func appendBinaryFrame(b []byte, f *Frame) []byte {
+	size := 8 + 4 + 1 + len(f.Organize) + 8 + len(f.Data)
+	b = binary.BigEndian.AppendUint32(b, uint32(size))
+	b = binary.BigEndian.AppendUint64(b, uint64(f.Received.UnixNano()))
+	b = binary.BigEndian.AppendUint32(b, uint32(f.Conn))
+	b = append(b, byte(len(f.Organize))|recordOffsetFlag)
+	b = append(b, f.Organize...)
+	b = binary.BigEndian.AppendUint64(b, uint64(f.Offset))
+	return append(b, f.Data...)
+}
+
+//记录文件读取
+//按文件扩展名判断格式和压缩方式
+type FrameReader struct {
+	format  RecordFormat
+	file    *os.File
+	decoder io.Closer //解压读取, 不压缩时为nil
+	reader  *bufio.Reader
+}
+
+//打开记录文件
+func OpenFrameReader(path string) (*FrameReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &FrameReader{file: file}
+	var in io.Reader = file
+	name := filepath.Base(path)
+	switch {
+	case strings.HasSuffix(name, "."+string(RecordGzip)):
+		dec, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		r.decoder, in = dec, dec
+		name = strings.TrimSuffix(name, "."+string(RecordGzip))
+	case strings.HasSuffix(name, "."+string(RecordZstd)):
+		dec, err := zstd.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		r.decoder, in = dec.IOReadCloser(), dec
+		name = strings.TrimSuffix(name, "."+string(RecordZstd))
+	}
+
+	r.format = RecordJson
+	switch {
+	case strings.HasSuffix(name, "."+string(RecordBinary)):
+		r.format = RecordBinary
+	case strings.HasSuffix(name, "."+string(RecordMarketer)):
+		r.format = RecordMarketer
+	}
+	r.reader = bufio.NewReader(in)
+	return r, nil
+}
+
+//读取下一条记录
+//读取完成返回io.EOF
+func (r *FrameReader) Next() (*Frame, error) {
+	if r.format == RecordMarketer {
+		return nil, errors.New("深度行情记录使用NextMarketer读取")
+	}
+	if r.format == RecordJson {
+		line, err := r.reader.ReadBytes('\n')
+		if err != nil {
+			if err == io.EOF && len(line) > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+
+		f := &Frame{}
+		return f, json.Unmarshal(line, f)
+	}
+
+	b, err := r.readBinary()
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 13 {
+		return nil, errors.New("记录长度错误")
+	}
+
+	n := 13 + int(b[12]&^recordOffsetFlag)
+	end := n
+	if b[12]&recordOffsetFlag != 0 {
+		end += 8
+	}
+	if len(b) < end {
+		return nil, errors.New("记录长度错误")
+	}
+
+	f := &Frame{
+		Received: time.Unix(0, int64(binary.BigEndian.Uint64(b[0:8]))),
+		Conn:     int(int32(binary.BigEndian.Uint32(b[8:12]))),
+		Organize: Organize(b[13:n]),
+		Data:     string(b[end:]),
+	}
+	if end > n {
+		f.Offset = time.Duration(binary.BigEndian.Uint64(b[n:end]))
+	}
+	return f, nil
+}
+
+//读取下一条深度行情
+//只能读取RecordMarketer格式, 读取完成返回io.EOF
+func (r *FrameReader) NextMarketer() (*Marketer, error) {
+	if r.format != RecordMarketer {
+		return nil, errors.New("不是深度行情记录")
+	}
+
+	b, err := r.readBinary()
+	if err != nil {
+		return nil, err
+	}
+	return DecodeMarketer(b)
+}
+
+//读取一条长度前缀的二进制记录
+func (r *FrameReader) readBinary() ([]byte, error) {
+	var head [4]byte
+	_, err := io.ReadFull(r.reader, head[:])
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, binary.BigEndian.Uint32(head[:]))
+	_, err = io.ReadFull(r.reader, b)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+//关闭记录文件
+func (r *FrameReader) Close() error {
+	if r.decoder != nil {
+		r.decoder.Close()
+	}
+	return r.file.Close()
+}
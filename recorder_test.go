@@ -0,0 +1,210 @@
+package market
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+//读取目录中所有记录文件
+func readFrames(t *testing.T, dir string) ([]string, []*Frame) {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join(dir, "market-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+
+	var frames []*Frame
+	for _, name := range files {
+		r, err := OpenFrameReader(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for {
+			f, err := r.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(name, err)
+			}
+			frames = append(frames, f)
+		}
+		r.Close()
+	}
+	return files, frames
+}
+
+func TestRecorder_Rotate(t *testing.T) {
+	for _, format := range []RecordFormat{RecordJson, RecordBinary} {
+		for _, compress := range []RecordCompress{RecordNone, RecordGzip, RecordZstd} {
+			dir := t.TempDir()
+			r, err := NewRecorder(RecorderOptions{
+				Dir:      dir,
+				Format:   format,
+				Compress: compress,
+				MaxAge:   time.Minute,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start := time.Unix(1583049600, 123)
+			for i := 0; i < 4; i++ {
+				err := r.Write(&Frame{
+					Received: start.Add(time.Duration(i) * 40 * time.Second),
+					Organize: HuoBi,
+					Conn:     i,
+					Offset:   time.Duration(i) * time.Millisecond,
+					Data:     `{"ch":"market.ethusdt.depth.step1","seq":` + strconv.Itoa(i) + `}`,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := r.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			files, frames := readFrames(t, dir)
+			if len(files) != 2 || len(frames) != 4 {
+				t.Fatal(format, compress, files, len(frames))
+			}
+			for i, f := range frames {
+				if !f.Received.Equal(start.Add(time.Duration(i)*40*time.Second)) || f.Organize != HuoBi || f.Conn != i || f.Offset != time.Duration(i)*time.Millisecond ||
+					f.Data != `{"ch":"market.ethusdt.depth.step1","seq":`+strconv.Itoa(i)+`}` {
+					t.Fatal(format, compress, f)
+				}
+			}
+		}
+	}
+}
+
+func TestFrameReader_NoOffset(t *testing.T) {
+	//没有时钟偏移的二进制记录
+	b := binary.BigEndian.AppendUint32(nil, uint32(8+4+1+len(OkEx)+2))
+	b = binary.BigEndian.AppendUint64(b, 1583049600e9)
+	b = binary.BigEndian.AppendUint32(b, 1)
+	b = append(b, byte(len(OkEx)))
+	b = append(append(b, OkEx...), "{}"...)
+
+	path := filepath.Join(t.TempDir(), "market-old.bin")
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := OpenFrameReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	f, err := r.Next()
+	if err != nil || f.Organize != OkEx || f.Conn != 1 || f.Offset != 0 || f.Data != "{}" || f.Received.Unix() != 1583049600 {
+		t.Fatal(f, err)
+	}
+}
+
+func TestRecorder_MaxSize(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRecorder(RecorderOptions{
+		Dir:     dir,
+		Format:  RecordBinary,
+		MaxSize: 100,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		r.Write(&Frame{Received: now.Add(time.Duration(i)), Organize: OkEx, Data: "0123456789012345678901234567890123456789"})
+	}
+	r.Close()
+
+	files, frames := readFrames(t, dir)
+	if len(files) != 3 || len(frames) != 5 {
+		t.Fatal(files, len(frames))
+	}
+}
+
+func TestRecorder_Worker(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRecorder(RecorderOptions{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetRecorder(r)
+	defer SetRecorder(nil)
+
+	m := newMockExchange(t, OkEx)
+	g := newMockGroup(t, m, newOkEx)
+	g.subscribeHandle(&Subscriber{
+		Symbol:     "LTC-USDT",
+		Organize:   OkEx,
+		MarketType: SpotMarket,
+	})
+	m.waitSub(t, "spot/depth5:LTC-USDT")
+
+	push := `{"table":"spot/depth5","data":[{"asks":[["60.1","1"]],"bids":[["60","2"]],"instrument_id":"LTC-USDT","timestamp":"2020-03-01T08:00:00.123Z"}]}`
+	m.push(push)
+	waitMarketer(t, "LTC-USDT")
+	SetRecorder(nil)
+	r.Close()
+
+	_, frames := readFrames(t, dir)
+	for _, f := range frames {
+		if f.Data == push {
+			if f.Organize != OkEx || f.Received.IsZero() {
+				t.Fatal(f)
+			}
+			return
+		}
+	}
+	t.Fatal("没有记录推送数据", len(frames))
+}
+
+func TestRecorder_Marketer(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRecorder(RecorderOptions{Dir: dir, Format: RecordMarketer, Compress: RecordZstd})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//原始数据不记录
+	received := time.Unix(1583049600, 0)
+	r.record(&Worker{Organize: OkEx}, received, 0, []byte("{}"))
+	r.save(&BBO{Event: Event{Type: BBOEvent, Organize: OkEx, Symbol: "BTC-USDT"}})
+	for i := 0; i < 3; i++ {
+		r.save(&Marketer{Organize: OkEx, Symbol: "BTC-USDT", BuyFirst: "9000." + strconv.Itoa(i+1), BuyDepth: Depth{{"9000." + strconv.Itoa(i+1), "1"}}, ReceivedAt: received})
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "market-*.mkt.zst"))
+	if len(files) != 1 {
+		t.Fatal(files)
+	}
+	fr, err := OpenFrameReader(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fr.Close()
+
+	for i := 0; i < 3; i++ {
+		m, err := fr.NextMarketer()
+		if err != nil || m.BuyFirst != "9000."+strconv.Itoa(i+1) || !m.ReceivedAt.Equal(received) {
+			t.Fatal(i, m, err)
+		}
+	}
+	if _, err := fr.NextMarketer(); err != io.EOF {
+		t.Fatal(err)
+	}
+}
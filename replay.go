@@ -0,0 +1,167 @@
+package market
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+//回放数据来源
+//FrameReader按记录顺序返回数据
+type FrameSource interface {
+	Next() (*Frame, error)
+}
+
+//回放数据最大的连接编号
+//记录的连接编号超过时认为数据错误, 防止创建过多的连接
+const replayMaxConn = 1000
+
+//回放记录的原始数据
+//数据经过和实时数据相同的formatMsgHandle, 合并和list处理
+//回放使用独立的worker集合和pool, 不连接交易所, 不推送到录制, 归档, 转发和消息总线, 不影响实时数据
+type Replay struct {
+	Speed          float64                   //回放速度, 1为原始速度, 2为两倍速度, 0为最快速度
+	ReadMarketPool readMarketer              //回放的深度行情, 和实时的ReadMarketPool相同处理
+	ReadEventPool  readEventer               //回放的其他事件, 和实时的ReadEventPool相同处理
+	tasks          map[Organize]*workerGroup //回放使用的worker集合
+	now            int64                     //回放时钟, 最后一条数据的接收时间(unix纳秒), 原子操作
+	lastGc         time.Time                 //最后一次list gc的回放时间
+}
+
+//创建回放
+//speed为回放速度, 0为最快速度
+func NewReplay(speed float64) *Replay {
+	markets := make(chan *Marketer, cap(readWriteMarketer))
+	events := make(chan Eventer, cap(readWriteEventer))
+	out := &output{
+		markets: &writeMarketer{buffer: markets},
+		events:  &writeEventer{buffer: events},
+	}
+	out.markets.gaps = out
+
+	tasks := newWorkerGroups(context.Background())
+	for _, g := range tasks {
+		g.out = out
+		g.seqs.out = out
+	}
+
+	return &Replay{
+		Speed:          speed,
+		ReadMarketPool: markets,
+		ReadEventPool:  events,
+		tasks:          tasks,
+	}
+}
+
+//回放前加入订阅
+//深度档位和热备订阅和实时订阅相同处理, 不会发送订阅
+func (r *Replay) Subscribe(s *Subscriber) {
+	if g := routeOf(r.tasks, s); g != nil {
+		g.subscribeHandle(s)
+	}
+}
+
+//回放时钟
+//返回最后一条回放数据的接收时间
+func (r *Replay) Now() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&r.now))
+}
+
+//查询回放后的行情数据
+func (r *Replay) Find(organize Organize, symbol ...string) map[string]*Marketer {
+	g, ok := r.tasks[organize]
+	if !ok {
+		return nil
+	}
+	return g.List.Find(symbol...).ToMap()
+}
+
+//按速度回放所有数据
+//读取完成返回nil, context关闭返回context错误
+//单条数据解析失败只记录日志, 和实时数据相同
+func (r *Replay) Run(ctx context.Context, src FrameSource) error {
+	var first, start time.Time
+	for {
+		f, err := src.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if r.Speed > 0 {
+			if first.IsZero() {
+				first, start = f.Received, time.Now()
+			}
+
+			due := start.Add(time.Duration(float64(f.Received.Sub(first)) / r.Speed))
+			if d := time.Until(due); d > 0 {
+				timer := time.NewTimer(d)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return ctx.Err()
+				case <-timer.C:
+				}
+			}
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		r.Feed(f)
+	}
+}
+
+//按顺序回放多个记录文件
+func (r *Replay) RunFiles(ctx context.Context, paths ...string) error {
+	for _, path := range paths {
+		reader, err := OpenFrameReader(path)
+		if err != nil {
+			return err
+		}
+
+		err = r.Run(ctx, reader)
+		reader.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//立即处理一条数据
+//回放时钟前进到数据的接收时间, 超过gc时间后执行list gc
+//需要按接收时间顺序调用, 不能并发调用
+func (r *Replay) Feed(f *Frame) error {
+	g, ok := r.tasks[f.Organize]
+	if !ok || f.Conn < 0 || f.Conn >= replayMaxConn {
+		return errors.New("不支持的回放数据 " + string(f.Organize) + " conn " + strconv.Itoa(f.Conn))
+	}
+
+	g.lock.Lock()
+	for len(g.workers) <= f.Conn {
+		g.add()
+	}
+	w := g.workers[f.Conn]
+	g.lock.Unlock()
+
+	atomic.StoreInt64(&r.now, f.Received.UnixNano())
+	if r.lastGc.IsZero() {
+		r.lastGc = f.Received
+	}
+	if f.Received.Sub(r.lastGc) >= workerListGcTime*time.Second {
+		r.lastGc = f.Received
+		for _, g := range r.tasks {
+			g.List.gc(f.Received, workerListGcTime*time.Second)
+		}
+	}
+
+	msg := []byte(f.Data)
+	data, err := w.handler.formatMsgHandle(msg, w)
+	return w.dispatch(data, err, f.Received, f.Offset, msg)
+}
@@ -0,0 +1,195 @@
+package market
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//okex深度数据
+func okexDepthFrame(received time.Time, symbol string, bid string) *Frame {
+	return &Frame{
+		Received: received,
+		Organize: OkEx,
+		Data:     `{"table":"spot/depth5","data":[{"asks":[["9000.1","1"]],"bids":[["` + bid + `","2"]],"instrument_id":"` + symbol + `","timestamp":"` + received.Add(-30*time.Millisecond).UTC().Format(time.RFC3339Nano) + `"}]}`,
+	}
+}
+
+//按顺序返回数据
+type frameSlice []*Frame
+
+func (s *frameSlice) Next() (*Frame, error) {
+	if len(*s) == 0 {
+		return nil, io.EOF
+	}
+	f := (*s)[0]
+	*s = (*s)[1:]
+	return f, nil
+}
+
+func TestReplay_Feed(t *testing.T) {
+	r := NewReplay(0)
+	r.Subscribe(&Subscriber{
+		Symbol:     "BTC-USDT",
+		Organize:   OkEx,
+		MarketType: SpotMarket,
+	})
+
+	start := time.Unix(1583049600, 0)
+	frames := frameSlice{
+		okexDepthFrame(start, "BTC-USDT", "9000"),
+		okexDepthFrame(start.Add(time.Second), "ETH-USDT", "230"),
+		okexDepthFrame(start.Add(3*time.Second), "ETH-USDT", "231"),
+	}
+	err := r.Run(context.Background(), &frames)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !r.Now().Equal(start.Add(3 * time.Second)) {
+		t.Fatal(r.Now())
+	}
+
+	//BTC-USDT超过gc时间被删除
+	m := r.Find(OkEx, "BTC-USDT", "ETH-USDT")
+	if _, ok := m["BTC-USDT"]; ok || m["ETH-USDT"] == nil {
+		t.Fatal(m)
+	}
+	e := m["ETH-USDT"]
+	if e.BuyFirst != "231" || e.Temporize != 30*time.Millisecond || !e.ReceivedAt.Equal(start.Add(3*time.Second)) {
+		t.Fatal(e)
+	}
+}
+
+func TestReplay_Speed(t *testing.T) {
+	r := NewReplay(10)
+
+	start := time.Now()
+	frames := frameSlice{
+		okexDepthFrame(start, "LINK-USDT", "4"),
+		okexDepthFrame(start.Add(500*time.Millisecond), "LINK-USDT", "4.1"),
+	}
+	err := r.Run(context.Background(), &frames)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := time.Since(start); d < 50*time.Millisecond || d > 400*time.Millisecond {
+		t.Fatal(d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	frames = frameSlice{okexDepthFrame(start, "LINK-USDT", "4")}
+	if err := r.Run(ctx, &frames); err != context.Canceled {
+		t.Fatal(err)
+	}
+}
+
+func TestReplay_RunFiles(t *testing.T) {
+	dir := t.TempDir()
+	rec, err := NewRecorder(RecorderOptions{Dir: dir, Format: RecordBinary, Compress: RecordZstd})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	rec.Write(&Frame{Received: now, Organize: HuoBi, Data: `{"id":"market.dotusdt.depth.step1","status":"ok","subbed":"market.dotusdt.depth.step1"}`})
+	rec.Write(&Frame{Received: now.Add(time.Millisecond), Organize: HuoBi, Data: `{"ch":"market.dotusdt.depth.step1","ts":1583049600123,"tick":{"bids":[[5.1,2]],"asks":[[5.2,1]],"version":7}}`})
+	rec.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "market-*"))
+	r := NewReplay(0)
+	err = r.RunFiles(context.Background(), files...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := r.Find(HuoBi, "dotusdt")["dotusdt"]
+	if e == nil || e.BuyFirst != "5.1" || e.ExchangeSeq != 7 {
+		t.Fatal(e)
+	}
+	select {
+	case e := <-r.ReadMarketPool:
+		if e.SellFirst != "5.2" {
+			t.Fatal(e)
+		}
+	default:
+		t.Fatal("没有写入回放pool")
+	}
+}
+
+func TestReplay_Isolation(t *testing.T) {
+	sink := &memorySink{}
+	p := NewPublisher(sink, SinkOptions{FlushInterval: 5 * time.Millisecond})
+	SetPublisher(p)
+	defer SetPublisher(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Run(ctx)
+
+	reg := prometheus.NewRegistry()
+	if err := RegisterMetrics(reg); err != nil {
+		t.Fatal(err)
+	}
+	defer Manage.metrics.Store((*metricSet)(nil))
+	decodeErrors := 0
+	SetHooks(&Hooks{OnDecodeError: func(organize Organize, raw []byte, err error) { decodeErrors++ }})
+	defer SetHooks(nil)
+
+	//使用记录的时钟偏移计算Latency
+	r := NewReplay(0)
+	start := time.Unix(1583049600, 0)
+	f := okexDepthFrame(start, "REPLAY-USDT", "1")
+	f.Offset = 5 * time.Millisecond
+	if err := r.Feed(f); err != nil {
+		t.Fatal(err)
+	}
+	if e := <-r.ReadMarketPool; e.Symbol != "REPLAY-USDT" || e.Seq != 1 || e.Latency != 35*time.Millisecond {
+		t.Fatal(e)
+	}
+
+	//回放数据不记录指标, 不调用回调
+	if err := r.Feed(&Frame{Received: start, Organize: OkEx, Data: `{"table":"spot/depth5","data":[]}`}); err == nil {
+		t.Fatal("没有返回错误")
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() == "market_messages_total" || f.GetName() == "market_decode_errors_total" {
+			t.Fatal(f)
+		}
+	}
+	if decodeErrors != 0 {
+		t.Fatal(decodeErrors)
+	}
+
+	//记录的连接编号超过上限时不创建连接
+	f = okexDepthFrame(start, "REPLAY-USDT", "2")
+	f.Conn = replayMaxConn
+	if err := r.Feed(f); err == nil || len(r.tasks[OkEx].workers) != 1 {
+		t.Fatal(err, len(r.tasks[OkEx].workers))
+	}
+
+	//回放数据不写入实时pool, 不推送到消息总线
+	time.Sleep(20 * time.Millisecond)
+	if sink.count() != 0 {
+		t.Fatal(sink.count())
+	}
+	for {
+		select {
+		case m := <-ReadMarketPool:
+			if m.Symbol == "REPLAY-USDT" {
+				t.Fatal(m)
+			}
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package market
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+//断档原因
+type GapReason string
+
+//ws断线重连, 重连期间的数据丢失
+const GapReconnect GapReason = "reconnect"
+
+//环形缓冲区已满, 删除了最旧的数据
+const GapRingBuffer GapReason = "ring_buffer"
+
+//交易所序号没有递增, 数据乱序或者重复, 或者连续序号跳过
+const GapExchangeSeq GapReason = "exchange_seq"
+
+//交易所序号连续递增的数据流, key为交易所和数据类型
+//序号跳过时推送断档事件, 其他数据流的交易所序号只保证递增, 只检查乱序和重复
+//火币深度的version和最优买卖价的seqId都不连续, 默认没有
+//需要在Run之前设置
+var ContiguousSeqs = map[Organize]map[EventType]bool{}
+
+//单个数据流的序号状态
+type seqState struct {
+	stream   EventType     //数据类型
+	organize Organize      //交易所
+	symbol   string        //合约或者币对
+	local    uint64        //本地递增序号
+	exchange uint64        //最后收到的交易所序号
+	source   *Worker       //最后推送该数据流的连接
+	standby  bool          //热备数据流, 多个连接推送相同数据
+	mark     uint64        //热备数据流最后推送的交易所序号或者时间
+	hashes   []uint64      //热备数据流没有交易所序号时, mark时间已推送数据的内容hash
+	updated  time.Time     //最后推送的本地时间
+	latency  time.Duration //最后推送的网络延迟
+}
+
+//按数据类型和币对记录序号
+//每个worker集合和poller各自记录
+type sequences struct {
+	data map[string]*seqState
+	out  *output //断档事件的输出目标
+	lock sync.Mutex
+}
+
+func newSequences() *sequences {
+	return &sequences{
+		data: make(map[string]*seqState),
+		out:  liveOutput,
+	}
+}
+
+//返回数据流的序号状态, 不存在时创建
+//调用方需要持有lock
+func (s *sequences) state(b *Event) *seqState {
+	key := string(b.Type) + ":" + b.Symbol
+	st, ok := s.data[key]
+	if !ok {
+		st = &seqState{stream: b.Type, organize: b.Organize, symbol: b.Symbol}
+		s.data[key] = st
+	}
+
+	return st
+}
+
+//标记为热备数据流
+func (s *sequences) standby(organize Organize, stream EventType, symbol string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.state(&Event{Type: stream, Organize: organize, Symbol: symbol}).standby = true
+}
+
+//更新数据流的最后推送时间
+//订阅和重新订阅时调用, 重新开始计算停止推送时间
+func (s *sequences) touch(organize Organize, stream EventType, symbol string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.state(&Event{Type: stream, Organize: organize, Symbol: symbol}).updated = time.Now()
+}
+
+//返回数据流的最后推送时间
+func (s *sequences) updated(organize Organize, stream EventType, symbol string) time.Time {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.state(&Event{Type: stream, Organize: organize, Symbol: symbol}).updated
+}
+
+//给事件分配本地序号
+//交易所序号没有递增, 或者ContiguousSeqs的数据流序号跳过时推送断档事件
+//热备数据流按交易所序号或者时间和内容去重, 重复数据返回false
+//source为推送该事件的连接, poller为nil
+func (s *sequences) next(e Eventer, source *Worker) bool {
+	seq, exchangeSeq := seqOf(e)
+	b := e.Base()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	st := s.state(b)
+	if st.standby && st.duplicate(e, exchangeSeq, b.Timestamp) {
+		return false
+	}
+
+	st.local++
+	st.source = source
+	st.updated = time.Now()
+	if !b.Timestamp.IsZero() {
+		st.latency = st.updated.Sub(b.Timestamp)
+	}
+	*seq = st.local
+
+	if exchangeSeq != 0 {
+		if st.exchange != 0 && (exchangeSeq <= st.exchange || exchangeSeq > st.exchange+1 && ContiguousSeqs[b.Organize][b.Type]) {
+			s.out.event(newGap(b, GapExchangeSeq, st.local-1))
+		}
+		st.exchange = exchangeSeq
+	}
+	return true
+}
+
+//热备数据流的重复数据
+//有交易所序号时按序号去重, 否则按交易所时间和内容去重, 同一时间内容不同的数据都推送
+//调用方需要持有lock
+func (st *seqState) duplicate(e Eventer, exchangeSeq uint64, timestamp time.Time) bool {
+	if exchangeSeq != 0 {
+		if exchangeSeq <= st.mark {
+			return true
+		}
+		st.mark = exchangeSeq
+		return false
+	}
+
+	mark := uint64(timestamp.UnixNano())
+	if mark < st.mark {
+		return true
+	}
+	if mark > st.mark {
+		st.mark, st.hashes = mark, st.hashes[:0]
+	}
+
+	hash := contentHash(e)
+	for _, v := range st.hashes {
+		if v == hash {
+			return true
+		}
+	}
+	st.hashes = append(st.hashes, hash)
+	return false
+}
+
+//数据内容的hash
+//深度行情不包含本地接收时间和延迟, 其他事件在分配本地序号前计算
+func contentHash(e Eventer) uint64 {
+	h := fnv.New64a()
+	if m, ok := e.(*Marketer); ok {
+		for _, v := range []string{m.BuyFirst, m.BuyFirstSize, m.SellFirst, m.SellFirstSize} {
+			h.Write([]byte(v))
+			h.Write([]byte{0})
+		}
+		for _, d := range []Depth{m.BuyDepth, m.SellDepth} {
+			for _, v := range d {
+				h.Write([]byte(v[0]))
+				h.Write([]byte{0})
+				h.Write([]byte(v[1]))
+				h.Write([]byte{0})
+			}
+			h.Write([]byte{1})
+		}
+		return h.Sum64()
+	}
+
+	b, _ := json.Marshal(e)
+	h.Write(b)
+	return h.Sum64()
+}
+
+//该连接的所有数据流推送断档事件
+//热备数据流由其他连接继续推送, 不推送断档事件
+//重连后调用
+func (s *sequences) gaps(reason GapReason, source *Worker) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, st := range s.data {
+		if st.source != source || st.standby {
+			continue
+		}
+		s.out.event(newGap(&Event{
+			Type:     st.stream,
+			Organize: st.organize,
+			Symbol:   st.symbol,
+		}, reason, st.local))
+	}
+}
+
+//返回事件的本地序号字段和交易所序号
+func seqOf(e Eventer) (*uint64, uint64) {
+	if m, ok := e.(*Marketer); ok {
+		return &m.Seq, m.ExchangeSeq
+	}
+
+	b := e.Base()
+	return &b.Seq, b.ExchangeSeq
+}
+
+//断档事件
+//LastSeq之后的数据可能丢失
+type Gap struct {
+	Event
+	Stream  EventType `json:"stream"`   //断档的数据类型
+	Reason  GapReason `json:"reason"`   //断档原因
+	LastSeq uint64    `json:"last_seq"` //断档前最后的本地序号
+}
+
+func newGap(b *Event, reason GapReason, lastSeq uint64) *Gap {
+	return &Gap{
+		Event: Event{
+			Type:      GapEvent,
+			Organize:  b.Organize,
+			Symbol:    b.Symbol,
+			Timestamp: time.Now(),
+		},
+		Stream:  b.Type,
+		Reason:  reason,
+		LastSeq: lastSeq,
+	}
+}
+
+//按JsonMillisecond序列化
+func (e *Gap) MarshalJSON() ([]byte, error) {
+	type alias Gap
+	if !JsonMillisecond {
+		return json.Marshal((*alias)(e))
+	}
+
+	return json.Marshal(&struct {
+		*alias
+		Timestamp int64 `json:"timestamp,omitempty"`
+	}{alias: (*alias)(e), Timestamp: millisecond(e.Timestamp)})
+}
@@ -0,0 +1,92 @@
+package market
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSequences_Next(t *testing.T) {
+	s := newSequences()
+
+	m := NewTestMarketer()
+	m.Symbol = "ethusdt"
+	m.ExchangeSeq = 10
+	s.next(m, nil)
+
+	n := NewTestMarketer()
+	n.Symbol = "ethusdt"
+	n.ExchangeSeq = 9
+	s.next(n, nil)
+
+	if m.Seq != 1 || n.Seq != 2 {
+		t.Fatal(m.Seq, n.Seq)
+	}
+
+	gap := waitEvent(t, func(e Eventer) bool {
+		_, ok := e.(*Gap)
+		return ok
+	}).(*Gap)
+	if gap.Reason != GapExchangeSeq || gap.LastSeq != 1 {
+		t.Fatal(gap)
+	}
+}
+
+func TestSequences_Contiguous(t *testing.T) {
+	gaps := make(chan Eventer, 10)
+	s := newSequences()
+	s.out = &output{events: &writeEventer{buffer: gaps}}
+
+	ContiguousSeqs[OkEx] = map[EventType]bool{BBOEvent: true}
+	defer delete(ContiguousSeqs, OkEx)
+
+	for _, seq := range []uint64{1, 2, 5} {
+		s.next(&BBO{Event: Event{Type: BBOEvent, Organize: OkEx, Symbol: "BTC-USDT", ExchangeSeq: seq}}, nil)
+	}
+	//其他数据流的序号跳过不是断档
+	for _, seq := range []uint64{1, 5} {
+		s.next(&BBO{Event: Event{Type: BBOEvent, Organize: HuoBi, Symbol: "btcusdt", ExchangeSeq: seq}}, nil)
+	}
+
+	if len(gaps) != 1 {
+		t.Fatal(len(gaps))
+	}
+	gap := (<-gaps).(*Gap)
+	if gap.Organize != OkEx || gap.Stream != BBOEvent || gap.Reason != GapExchangeSeq || gap.LastSeq != 2 {
+		t.Fatal(gap)
+	}
+}
+
+func TestSequences_Standby(t *testing.T) {
+	s := newSequences()
+	s.standby(OkEx, DepthEvent, "BTC-USDT")
+
+	m := NewTestMarketer()
+	m.Organize = OkEx
+	m.Symbol = "BTC-USDT"
+	dup := *m
+
+	if !s.next(m, nil) {
+		t.Fatal("first update dropped")
+	}
+	if s.next(&dup, nil) {
+		t.Fatal("duplicate update not dropped")
+	}
+
+	//同一毫秒内容不同的数据都推送, 之前时间的数据丢弃
+	next := *m
+	next.BuyFirst = "123214"
+	if !s.next(&next, nil) || m.Seq != 1 || next.Seq != 2 {
+		t.Fatal("distinct update in the same millisecond dropped", m.Seq, next.Seq)
+	}
+	dup = next
+	dup.ReceivedAt = next.ReceivedAt.Add(time.Millisecond)
+	if s.next(&dup, nil) {
+		t.Fatal("duplicate update not dropped")
+	}
+	old := *m
+	old.Timestamp = m.Timestamp.Add(-time.Millisecond)
+	old.BuyFirst = "1"
+	if s.next(&old, nil) {
+		t.Fatal("older update not dropped")
+	}
+}
@@ -0,0 +1,226 @@
+package market
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+//消息总线的一条消息
+type SinkMessage struct {
+	Organize Organize
+	Symbol   string
+	Type     EventType
+	Value    []byte //序列化后的事件
+}
+
+//消息总线
+//Publish一次推送一批消息, 返回错误时整批重试, 实现需要保证重复推送时不会出错
+type Sink interface {
+	Publish(ctx context.Context, msgs []*SinkMessage) error
+	Close() error
+}
+
+//消息编码方式
+type SinkEncoding string
+
+//json编码, 默认编码
+const SinkJson SinkEncoding = "json"
+
+//深度行情使用Codec二进制编码, 其他事件使用json编码
+//二进制编码第一个字节为CodecVersion, 消费方可以据此区分
+const SinkBinary SinkEncoding = "binary"
+
+//消息总线推送配置
+type SinkOptions struct {
+	Encoding      SinkEncoding  //消息编码方式, 默认json
+	BatchSize     int           //每批最多推送的消息数量, 默认100
+	FlushInterval time.Duration //不满一批时最长等待时间, 默认100毫秒
+	Buffer        int           //等待推送的消息数量, 超过后丢弃新的消息, 默认10000
+	Retry         *Backoff      //推送失败的重试策略, 默认DefaultSinkRetry
+}
+
+//默认推送重试策略
+//100毫秒开始, 每次翻倍, 最多重试5次后丢弃该批消息
+var DefaultSinkRetry = &Backoff{
+	Min:         100 * time.Millisecond,
+	Max:         5 * time.Second,
+	Factor:      2,
+	Jitter:      0.2,
+	MaxAttempts: 5,
+}
+
+//关闭时推送剩余消息的超时时间
+const sinkCloseTimeout = 5 * time.Second
+
+//消息总线推送
+//推送到pool的数据同时写入队列, Run批量推送到Sink
+type Publisher struct {
+	sink  Sink
+	opts  SinkOptions
+	queue chan *SinkMessage
+	codec *Codec //SinkBinary使用
+}
+
+//创建消息总线推送
+func NewPublisher(sink Sink, opts SinkOptions) *Publisher {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = 100 * time.Millisecond
+	}
+	if opts.Buffer <= 0 {
+		opts.Buffer = 10000
+	}
+	if opts.Retry == nil {
+		opts.Retry = DefaultSinkRetry
+	}
+	if opts.Encoding == "" {
+		opts.Encoding = SinkJson
+	}
+
+	return &Publisher{
+		sink:  sink,
+		opts:  opts,
+		queue: make(chan *SinkMessage, opts.Buffer),
+		codec: NewCodec(),
+	}
+}
+
+//设置消息总线推送
+//nil停止推送
+func SetPublisher(p *Publisher) {
+	Manage.publisher.Store(p)
+}
+
+//当前使用的消息总线推送
+func publisher() *Publisher {
+	p, _ := Manage.publisher.Load().(*Publisher)
+	return p
+}
+
+//写入推送队列
+//队列已满时丢弃, 不阻塞行情处理
+func (p *Publisher) push(data Eventer) {
+	if p == nil {
+		return
+	}
+
+	msg, err := p.message(data)
+	if err != nil {
+		logger().Warn("序列化推送数据失败", "err", err)
+		return
+	}
+
+	select {
+	case p.queue <- msg:
+	default:
+		metrics().drop("sink")
+	}
+}
+
+//批量推送队列中的消息
+//context关闭后推送剩余的消息和等待重试的一批消息, 然后关闭Sink
+func (p *Publisher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(p.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*SinkMessage, 0, p.opts.BatchSize)
+	for {
+		select {
+		case msg := <-p.queue:
+			batch = append(batch, msg)
+			if len(batch) < p.opts.BatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		case <-ctx.Done():
+			return p.close(batch)
+		}
+
+		if err := p.flush(ctx, batch); err != nil && err == ctx.Err() {
+			return p.close(batch)
+		}
+		batch = make([]*SinkMessage, 0, p.opts.BatchSize)
+	}
+}
+
+//推送一批消息
+//失败后按Retry重试, 超过重试次数或者context关闭后丢弃
+func (p *Publisher) flush(ctx context.Context, batch []*SinkMessage) error {
+	for attempt := 1; ; attempt++ {
+		err := p.sink.Publish(ctx, batch)
+		if err == nil {
+			return nil
+		}
+
+		if p.opts.Retry.MaxAttempts > 0 && attempt >= p.opts.Retry.MaxAttempts {
+			logger().Warn("推送消息失败, 丢弃消息", "count", len(batch), "attempt", attempt, "err", err)
+			for range batch {
+				metrics().drop("sink")
+			}
+			return err
+		}
+
+		delay := p.opts.Retry.delay(attempt)
+		logger().Warn("推送消息失败, 等待重试", "count", len(batch), "attempt", attempt, "delay", delay.String(), "err", err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+//推送剩余的消息并关闭Sink
+//只推送关闭时已经在队列中的消息, 之后写入的消息不再推送, 防止一直有数据写入时不能关闭
+func (p *Publisher) close(batch []*SinkMessage) error {
+	ctx, cancel := context.WithTimeout(context.Background(), sinkCloseTimeout)
+	defer cancel()
+
+	var err error
+	for n := len(p.queue); n > 0 || len(batch) > 0; n-- {
+		if n > 0 {
+			batch = append(batch, <-p.queue)
+			if len(batch) < p.opts.BatchSize && n > 1 {
+				continue
+			}
+		}
+
+		if e := p.flush(ctx, batch); e != nil && err == nil {
+			err = e
+		}
+		batch = make([]*SinkMessage, 0, p.opts.BatchSize)
+	}
+
+	if e := p.sink.Close(); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
+
+//事件转换为消息
+func (p *Publisher) message(data Eventer) (*SinkMessage, error) {
+	var value []byte
+	var err error
+	if m, ok := data.(*Marketer); ok && p.opts.Encoding == SinkBinary {
+		value, err = p.codec.AppendMarketer(nil, m)
+	} else {
+		value, err = json.Marshal(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	b := data.Base()
+	return &SinkMessage{
+		Organize: b.Organize,
+		Symbol:   b.Symbol,
+		Type:     b.Type,
+		Value:    value,
+	}, nil
+}
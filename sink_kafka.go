@@ -0,0 +1,61 @@
+package market
+
+import (
+	"context"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+//kafka写入接口
+//测试时替换为本地实现
+type kafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+//kafka消息总线
+//topic为 prefix.organize, 例如market.okex, key为币对, 同一个币对写入同一个分区保证顺序
+//header type为事件类型
+type KafkaSink struct {
+	writer kafkaWriter
+	prefix string
+}
+
+//连接kafka
+//prefix为空时使用market, 等待所有副本确认
+func NewKafkaSink(brokers []string, prefix string) *KafkaSink {
+	return newKafkaSink(&kafka.Writer{
+		Addr:                   kafka.TCP(brokers...),
+		Balancer:               &kafka.Hash{},
+		RequiredAcks:           kafka.RequireAll,
+		AllowAutoTopicCreation: true,
+		BatchTimeout:           10 * time.Millisecond,
+	}, prefix)
+}
+
+func newKafkaSink(w kafkaWriter, prefix string) *KafkaSink {
+	if prefix == "" {
+		prefix = "market"
+	}
+	return &KafkaSink{writer: w, prefix: prefix}
+}
+
+//推送一批消息
+func (s *KafkaSink) Publish(ctx context.Context, msgs []*SinkMessage) error {
+	records := make([]kafka.Message, len(msgs))
+	for k, msg := range msgs {
+		records[k] = kafka.Message{
+			Topic:   s.prefix + "." + string(msg.Organize),
+			Key:     []byte(msg.Symbol),
+			Value:   msg.Value,
+			Headers: []kafka.Header{{Key: "type", Value: []byte(msg.Type)}},
+		}
+	}
+	return s.writer.WriteMessages(ctx, records...)
+}
+
+//关闭连接
+func (s *KafkaSink) Close() error {
+	return s.writer.Close()
+}
@@ -0,0 +1,63 @@
+package market
+
+import (
+	"context"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+//nats主题中不允许的字符
+var natsSubjectReplacer = strings.NewReplacer(".", "_", "*", "_", ">", "_", " ", "_")
+
+//nats消息总线
+//主题为 prefix.organize.symbol.type, 例如market.okex.BTC-USDT.depth
+type NatsSink struct {
+	conn   *nats.Conn
+	prefix string
+}
+
+//连接nats
+//prefix为空时使用market
+func NewNatsSink(url string, prefix string, opts ...nats.Option) (*NatsSink, error) {
+	conn, err := nats.Connect(url, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if prefix == "" {
+		prefix = "market"
+	}
+	return &NatsSink{conn: conn, prefix: prefix}, nil
+}
+
+//推送一批消息
+//等待服务端确认收到后返回, context没有超时时间时使用nats默认超时
+func (s *NatsSink) Publish(ctx context.Context, msgs []*SinkMessage) error {
+	for _, msg := range msgs {
+		if err := s.conn.Publish(s.subject(msg), msg.Value); err != nil {
+			return err
+		}
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		return s.conn.Flush()
+	}
+	return s.conn.FlushWithContext(ctx)
+}
+
+//消息主题
+func (s *NatsSink) subject(msg *SinkMessage) string {
+	return strings.Join([]string{
+		s.prefix,
+		natsSubjectReplacer.Replace(string(msg.Organize)),
+		natsSubjectReplacer.Replace(msg.Symbol),
+		string(msg.Type),
+	}, ".")
+}
+
+//关闭连接
+func (s *NatsSink) Close() error {
+	s.conn.Close()
+	return nil
+}
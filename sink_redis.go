@@ -0,0 +1,51 @@
+package market
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//redis stream消息总线
+//stream为 prefix:organize:symbol, 例如market:okex:BTC-USDT, 字段type为事件类型, data为事件
+type RedisSink struct {
+	client *redis.Client
+	prefix string
+	maxLen int64
+}
+
+//连接redis
+//prefix为空时使用market, maxLen为每个stream保留的大约消息数量, 0不限制
+func NewRedisSink(opts *redis.Options, prefix string, maxLen int64) *RedisSink {
+	if prefix == "" {
+		prefix = "market"
+	}
+	return &RedisSink{client: redis.NewClient(opts), prefix: prefix, maxLen: maxLen}
+}
+
+//推送一批消息
+//使用pipeline一次发送
+func (s *RedisSink) Publish(ctx context.Context, msgs []*SinkMessage) error {
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, msg := range msgs {
+			pipe.XAdd(ctx, &redis.XAddArgs{
+				Stream: s.stream(msg),
+				MaxLen: s.maxLen,
+				Approx: s.maxLen > 0,
+				Values: []interface{}{"type", string(msg.Type), "data", msg.Value},
+			})
+		}
+		return nil
+	})
+	return err
+}
+
+//消息stream
+func (s *RedisSink) stream(msg *SinkMessage) string {
+	return s.prefix + ":" + string(msg.Organize) + ":" + msg.Symbol
+}
+
+//关闭连接
+func (s *RedisSink) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,312 @@
+package market
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go"
+)
+
+//内存消息总线
+//前fail次推送返回错误
+type memorySink struct {
+	fail    int
+	batches [][]*SinkMessage
+	closed  bool
+	lock    sync.Mutex
+}
+
+func (s *memorySink) Publish(ctx context.Context, msgs []*SinkMessage) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.fail > 0 {
+		s.fail--
+		return errors.New("推送失败")
+	}
+	s.batches = append(s.batches, msgs)
+	return nil
+}
+
+func (s *memorySink) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closed = true
+	return nil
+}
+
+//推送成功的消息数量
+func (s *memorySink) count() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	n := 0
+	for _, b := range s.batches {
+		n += len(b)
+	}
+	return n
+}
+
+func TestPublisher_Run(t *testing.T) {
+	sink := &memorySink{fail: 2}
+	p := NewPublisher(sink, SinkOptions{
+		BatchSize:     3,
+		FlushInterval: 10 * time.Millisecond,
+		Retry:         &Backoff{Min: time.Millisecond, Max: time.Millisecond, Factor: 1, MaxAttempts: 5},
+	})
+	SetPublisher(p)
+	defer SetPublisher(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- p.Run(ctx) }()
+
+	for i := 0; i < 4; i++ {
+		publish(&Marketer{Organize: OkEx, Symbol: "SINK-USDT", Seq: uint64(i)})
+	}
+	for deadline := time.Now().Add(time.Second); sink.count() < 4 && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	//失败重试后按顺序推送, 第一批满3条, 剩余1条按FlushInterval推送
+	if len(sink.batches) != 2 || len(sink.batches[0]) != 3 || len(sink.batches[1]) != 1 {
+		t.Fatal(sink.batches)
+	}
+	if m := sink.batches[0][0]; m.Organize != OkEx || m.Symbol != "SINK-USDT" || m.Type != DepthEvent || !strings.Contains(string(m.Value), `"symbol":"SINK-USDT"`) {
+		t.Fatal(m)
+	}
+
+	publish(&Marketer{Organize: OkEx, Symbol: "SINK-USDT", Seq: 4})
+	cancel()
+	if err := <-done; err != nil || sink.count() != 5 || !sink.closed {
+		t.Fatal(err, sink.count(), sink.closed)
+	}
+}
+
+func TestPublisher_Close(t *testing.T) {
+	//等待重试时context关闭, 这一批消息在关闭时推送
+	sink := &memorySink{fail: 1}
+	p := NewPublisher(sink, SinkOptions{BatchSize: 1, Retry: &Backoff{Min: time.Hour, Max: time.Hour, Factor: 1}})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- p.Run(ctx) }()
+
+	p.push(&Marketer{Organize: OkEx, Symbol: "SINK-USDT"})
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(5 * time.Millisecond) {
+		sink.lock.Lock()
+		fail := sink.fail
+		sink.lock.Unlock()
+		if fail == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("没有推送")
+		}
+	}
+	cancel()
+	if err := <-done; err != nil || sink.count() != 1 || !sink.closed {
+		t.Fatal(err, sink.count(), sink.closed)
+	}
+
+	//关闭时一直有消息写入, 只推送关闭时队列中的消息
+	sink = &memorySink{}
+	p = NewPublisher(sink, SinkOptions{BatchSize: 10, FlushInterval: time.Hour})
+	ctx, cancel = context.WithCancel(context.Background())
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				p.push(&Marketer{Organize: OkEx, Symbol: "SINK-USDT"})
+			}
+		}
+	}()
+	go func() { done <- p.Run(ctx) }()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil || !sink.closed {
+			t.Fatal(err, sink.closed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("关闭超时")
+	}
+}
+
+func TestPublisher_Drop(t *testing.T) {
+	sink := &memorySink{fail: 10}
+	p := NewPublisher(sink, SinkOptions{Retry: &Backoff{Min: time.Millisecond, Max: time.Millisecond, Factor: 1, MaxAttempts: 2}})
+
+	if err := p.flush(context.Background(), []*SinkMessage{{Symbol: "SINK-USDT"}}); err == nil || sink.fail != 8 {
+		t.Fatal(err, sink.fail)
+	}
+}
+
+//本地nats服务
+//只实现推送需要的协议
+type natsStandIn struct {
+	lis  net.Listener
+	msgs chan [2]string
+}
+
+func newNatsStandIn(t *testing.T) *natsStandIn {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lis.Close() })
+
+	s := &natsStandIn{lis: lis, msgs: make(chan [2]string, 100)}
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *natsStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+
+	fmt.Fprintf(conn, "INFO {\"server_id\":\"test\",\"version\":\"2.10.0\",\"proto\":1,\"max_payload\":1048576}\r\n")
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "PING":
+			conn.Write([]byte("PONG\r\n"))
+		case "PUB":
+			size, _ := strconv.Atoi(fields[len(fields)-1])
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return
+			}
+			s.msgs <- [2]string{fields[1], string(payload[:size])}
+		}
+	}
+}
+
+func TestNatsSink_Publish(t *testing.T) {
+	server := newNatsStandIn(t)
+	sink, err := NewNatsSink("nats://"+server.lis.Addr().String(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	err = sink.Publish(context.Background(), []*SinkMessage{
+		{Organize: OkEx, Symbol: "BTC-USDT", Type: DepthEvent, Value: []byte("1")},
+		{Organize: HuoBi, Symbol: "btc.usdt", Type: BBOEvent, Value: []byte("2")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//Publish等待服务端确认后返回
+	for _, want := range [][2]string{{"market.okex.BTC-USDT.depth", "1"}, {"market.huobi.btc_usdt.bbo", "2"}} {
+		select {
+		case msg := <-server.msgs:
+			if msg != want {
+				t.Fatal(msg)
+			}
+		default:
+			t.Fatal("没有收到消息", want)
+		}
+	}
+}
+
+func TestRedisSink_Publish(t *testing.T) {
+	server := miniredis.RunT(t)
+	sink := NewRedisSink(&redis.Options{Addr: server.Addr()}, "md", 1000)
+	defer sink.Close()
+
+	err := sink.Publish(context.Background(), []*SinkMessage{
+		{Organize: OkEx, Symbol: "BTC-USDT", Type: DepthEvent, Value: []byte(`{"seq":1}`)},
+		{Organize: OkEx, Symbol: "BTC-USDT", Type: BBOEvent, Value: []byte(`{"seq":2}`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	msgs, err := client.XRange(context.Background(), "md:okex:BTC-USDT", "-", "+").Result()
+	if err != nil || len(msgs) != 2 || msgs[0].Values["type"] != "depth" || msgs[1].Values["data"] != `{"seq":2}` {
+		t.Fatal(msgs, err)
+	}
+}
+
+//本地kafka写入
+type kafkaStandIn struct {
+	msgs []kafka.Message
+}
+
+func (w *kafkaStandIn) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.msgs = append(w.msgs, msgs...)
+	return nil
+}
+
+func (w *kafkaStandIn) Close() error {
+	return nil
+}
+
+func TestKafkaSink_Publish(t *testing.T) {
+	w := &kafkaStandIn{}
+	sink := newKafkaSink(w, "")
+
+	err := sink.Publish(context.Background(), []*SinkMessage{
+		{Organize: HuoBi, Symbol: "BTC-USD", Type: LiquidationEvent, Value: []byte("1")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(w.msgs) != 1 || w.msgs[0].Topic != "market.huobi" || string(w.msgs[0].Key) != "BTC-USD" ||
+		string(w.msgs[0].Headers[0].Value) != "liquidation" || string(w.msgs[0].Value) != "1" {
+		t.Fatal(w.msgs)
+	}
+}
+
+func TestPublisher_Binary(t *testing.T) {
+	p := NewPublisher(&memorySink{}, SinkOptions{Encoding: SinkBinary})
+
+	msg, err := p.message(&Marketer{Organize: OkEx, Symbol: "BTC-USDT", BuyFirst: "9000"})
+	if err != nil || msg.Value[0] != CodecVersion {
+		t.Fatal(msg, err)
+	}
+	if m, err := DecodeMarketer(msg.Value); err != nil || m.BuyFirst != "9000" {
+		t.Fatal(m, err)
+	}
+
+	msg, err = p.message(&BBO{Event: Event{Type: BBOEvent, Organize: OkEx, Symbol: "BTC-USDT"}, BidPrice: "9000"})
+	if err != nil || msg.Value[0] != '{' {
+		t.Fatal(msg, err)
+	}
+}
@@ -0,0 +1,183 @@
+package market
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+//快照根bucket
+//每个交易所币对一个子bucket, key为 交易所/币对
+var snapshotBucket = []byte("books")
+
+//快照配置
+type SnapshotOptions struct {
+	Path      string        //bbolt文件路径
+	Interval  time.Duration //快照间隔, 默认1分钟
+	Retention time.Duration //快照保留时间, 超过后删除, 0不删除
+}
+
+//深度行情快照
+//定时保存所有list中的深度行情, 按交易所/币对/交易所时间保存
+//用于查询某个时间点的深度
+type SnapshotStore struct {
+	opts SnapshotOptions
+	db   *bolt.DB
+}
+
+//打开快照文件
+//文件不存在时创建
+func OpenSnapshotStore(opts SnapshotOptions) (*SnapshotStore, error) {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Minute
+	}
+
+	db, err := bolt.Open(opts.Path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(snapshotBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SnapshotStore{opts: opts, db: db}, nil
+}
+
+//按间隔保存快照
+//context关闭后返回, 保存失败只记录日志
+func (s *SnapshotStore) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := s.Snapshot(now); err != nil {
+				logger().Warn("保存深度快照失败", "err", err)
+			}
+		}
+	}
+}
+
+//保存所有连接list中的深度行情
+//now用于删除超过保留时间的快照
+func (s *SnapshotStore) Snapshot(now time.Time) error {
+	var books []*Marketer
+	for _, g := range Manage.tasks {
+		books = append(books, g.List.values()...)
+	}
+	return s.Save(now, books...)
+}
+
+//保存深度行情
+//同一个交易所时间的深度只保存一次
+func (s *SnapshotStore) Save(now time.Time, books ...*Marketer) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		root := tx.Bucket(snapshotBucket)
+		for _, m := range books {
+			row, err := newArchiveRow(m)
+			if err != nil {
+				return err
+			}
+			v, err := json.Marshal(row)
+			if err != nil {
+				return err
+			}
+
+			b, err := root.CreateBucketIfNotExists(snapshotKey(m.Organize, m.Symbol))
+			if err != nil {
+				return err
+			}
+			if err := b.Put(snapshotTime(m.Timestamp), v); err != nil {
+				return err
+			}
+		}
+
+		if s.opts.Retention <= 0 {
+			return nil
+		}
+		return s.expire(root, now.Add(-s.opts.Retention))
+	})
+}
+
+//删除before之前的快照
+//每个币对保留最新的一条快照, 保证可以查询到最后的深度
+func (s *SnapshotStore) expire(root *bolt.Bucket, before time.Time) error {
+	end := snapshotTime(before)
+	return root.ForEach(func(name, _ []byte) error {
+		c := root.Bucket(name).Cursor()
+		last, _ := c.Last()
+		for k, _ := c.First(); k != nil && bytes.Compare(k, end) < 0 && !bytes.Equal(k, last); k, _ = c.First() {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+//查询某个时间点的深度
+//返回交易所时间小于等于at的最后一条快照, 没有快照时返回nil
+func (s *SnapshotStore) AsOf(organize Organize, symbol string, at time.Time) (*Marketer, error) {
+	var m *Marketer
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(snapshotBucket).Bucket(snapshotKey(organize, symbol))
+		if b == nil {
+			return nil
+		}
+
+		c := b.Cursor()
+		key := snapshotTime(at)
+		k, v := c.Seek(key)
+		switch {
+		case k == nil:
+			k, v = c.Last()
+		case !bytes.Equal(k, key):
+			k, v = c.Prev()
+		}
+		if k == nil {
+			return nil
+		}
+
+		row := &archiveRow{}
+		if err := json.Unmarshal(v, row); err != nil {
+			return err
+		}
+		e, err := row.eventer()
+		if err != nil {
+			return err
+		}
+		m = e.(*Marketer)
+		return nil
+	})
+	return m, err
+}
+
+//关闭快照文件
+func (s *SnapshotStore) Close() error {
+	return s.db.Close()
+}
+
+//交易所币对的bucket名称
+func snapshotKey(organize Organize, symbol string) []byte {
+	return []byte(string(organize) + "/" + symbol)
+}
+
+//快照时间key, 大端序unix纳秒, 按时间排序
+func snapshotTime(t time.Time) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	return key
+}
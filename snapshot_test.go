@@ -0,0 +1,90 @@
+package market
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshotStore_AsOf(t *testing.T) {
+	s, err := OpenSnapshotStore(SnapshotOptions{Path: filepath.Join(t.TempDir(), "books.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	start := time.Date(2020, 3, 1, 8, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		err := s.Save(start, &Marketer{
+			Organize:  OkEx,
+			Symbol:    "BTC-USDT",
+			BuyFirst:  "900" + string(rune('0'+i)),
+			BuyDepth:  Depth{{"900" + string(rune('0'+i)), "1"}},
+			Timestamp: start.Add(time.Duration(i) * time.Minute),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for at, want := range map[time.Duration]string{
+		-time.Second:              "",
+		0:                         "9000",
+		90 * time.Second:          "9001",
+		2 * time.Minute:           "9002",
+		time.Hour:                 "9002",
+		time.Minute - time.Second: "9000",
+	} {
+		m, err := s.AsOf(OkEx, "BTC-USDT", start.Add(at))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (m == nil && want != "") || (m != nil && (m.BuyFirst != want || m.BuyDepth[0][0] != want)) {
+			t.Fatal(at, m)
+		}
+	}
+
+	if m, _ := s.AsOf(HuoBi, "BTC-USDT", start.Add(time.Hour)); m != nil {
+		t.Fatal(m)
+	}
+}
+
+func TestSnapshotStore_Retention(t *testing.T) {
+	s, err := OpenSnapshotStore(SnapshotOptions{Path: filepath.Join(t.TempDir(), "books.db"), Retention: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	start := time.Date(2020, 3, 1, 8, 0, 0, 0, time.UTC)
+	s.Save(start, &Marketer{Organize: HuoBi, Symbol: "ethusdt", BuyFirst: "230", Timestamp: start})
+	s.Save(start, &Marketer{Organize: HuoBi, Symbol: "ethusdt", BuyFirst: "231", Timestamp: start.Add(time.Minute)})
+	s.Save(start.Add(2*time.Hour), &Marketer{Organize: OkEx, Symbol: "ETH-USDT", BuyFirst: "232", Timestamp: start.Add(2 * time.Hour)})
+
+	//超过保留时间的快照删除, 每个币对保留最新的快照
+	if m, _ := s.AsOf(HuoBi, "ethusdt", start.Add(30*time.Second)); m != nil {
+		t.Fatal(m)
+	}
+	if m, _ := s.AsOf(HuoBi, "ethusdt", start.Add(time.Hour)); m == nil || m.BuyFirst != "231" {
+		t.Fatal(m)
+	}
+}
+
+func TestSnapshotStore_Snapshot(t *testing.T) {
+	s, err := OpenSnapshotStore(SnapshotOptions{Path: filepath.Join(t.TempDir(), "books.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	ts := time.Now()
+	Manage.tasks[OkEx].List.Add("SNAP-USDT", &Marketer{Organize: OkEx, Symbol: "SNAP-USDT", BuyFirst: "1", Timestamp: ts})
+	defer Manage.tasks[OkEx].List.Del("SNAP-USDT")
+
+	if err := s.Snapshot(ts); err != nil {
+		t.Fatal(err)
+	}
+	if m, _ := s.AsOf(OkEx, "SNAP-USDT", ts); m == nil || m.BuyFirst != "1" {
+		t.Fatal(m)
+	}
+}
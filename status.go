@@ -0,0 +1,233 @@
+package market
+
+import (
+	"encoding/json"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+//运行状态
+//可以直接作为就绪检测的返回
+type StatusReport struct {
+	Ready bool          `json:"ready"` //有订阅的连接都已经连接成功, 并且有订阅的轮询没有连续失败
+	Tasks []*TaskStatus `json:"tasks"` //每个ws地址的状态
+	Polls []*PollStatus `json:"polls"` //每个交易所rest轮询的状态
+}
+
+//ws地址状态
+type TaskStatus struct {
+	Organize Organize        `json:"organize"` //交易所
+	Conns    []*ConnStatus   `json:"conns"`    //每个连接的状态
+	Streams  []*StreamStatus `json:"streams"`  //每个数据流的状态
+}
+
+//连接状态
+type ConnStatus struct {
+	Id          int         `json:"id"`           //连接编号
+	State       WorkerState `json:"state"`        //连接状态
+	LastMessage time.Time   `json:"last_message"` //最后收到ws数据的时间
+	Reconnects  int64       `json:"reconnects"`   //重连次数
+	Pending     []string    `json:"pending"`      //订阅中的主题
+	Confirmed   []string    `json:"confirmed"`    //订阅成功的主题
+}
+
+//按JsonMillisecond序列化
+func (c *ConnStatus) MarshalJSON() ([]byte, error) {
+	type alias ConnStatus
+	if !JsonMillisecond {
+		return json.Marshal((*alias)(c))
+	}
+
+	return json.Marshal(&struct {
+		*alias
+		LastMessage int64 `json:"last_message"`
+	}{alias: (*alias)(c), LastMessage: millisecond(c.LastMessage)})
+}
+
+//rest轮询状态
+type PollStatus struct {
+	Organize  Organize  `json:"organize"`   //交易所
+	Topics    []string  `json:"topics"`     //轮询的主题
+	LastPoll  time.Time `json:"last_poll"`  //最后一轮全部主题请求成功的时间
+	Failures  int       `json:"failures"`   //连续失败的轮数
+	LastError string    `json:"last_error"` //最后一次请求失败的错误
+}
+
+//按JsonMillisecond序列化
+func (p *PollStatus) MarshalJSON() ([]byte, error) {
+	type alias PollStatus
+	if !JsonMillisecond {
+		return json.Marshal((*alias)(p))
+	}
+
+	return json.Marshal(&struct {
+		*alias
+		LastPoll int64 `json:"last_poll"`
+	}{alias: (*alias)(p), LastPoll: millisecond(p.LastPoll)})
+}
+
+//连续失败没有超过pollMaxFailures
+func (p *PollStatus) healthy() bool {
+	return len(p.Topics) == 0 || p.Failures < pollMaxFailures
+}
+
+//数据流状态
+type StreamStatus struct {
+	Stream     EventType     `json:"stream"`      //数据类型
+	Symbol     string        `json:"symbol"`      //合约或者币对
+	Seq        uint64        `json:"seq"`         //最后推送的本地序号
+	LastUpdate time.Time     `json:"last_update"` //最后推送的本地时间
+	Temporize  time.Duration `json:"temporize"`   //最后推送的网络延迟
+}
+
+//按JsonMillisecond序列化
+func (s *StreamStatus) MarshalJSON() ([]byte, error) {
+	type alias StreamStatus
+	if !JsonMillisecond {
+		return json.Marshal((*alias)(s))
+	}
+
+	return json.Marshal(&struct {
+		*alias
+		LastUpdate int64 `json:"last_update"`
+		Temporize  int64 `json:"temporize"`
+	}{
+		alias:      (*alias)(s),
+		LastUpdate: millisecond(s.LastUpdate),
+		Temporize:  s.Temporize.Milliseconds(),
+	})
+}
+
+//返回所有ws地址和轮询的运行状态
+//没有订阅的连接不影响就绪
+func Status() *StatusReport {
+	report := &StatusReport{Ready: true}
+
+	organizes := make([]string, 0, len(Manage.tasks))
+	for k := range Manage.tasks {
+		organizes = append(organizes, string(k))
+	}
+	sort.Strings(organizes)
+
+	for _, k := range organizes {
+		t := Manage.tasks[Organize(k)].status()
+		for _, c := range t.Conns {
+			if len(c.Pending)+len(c.Confirmed) > 0 && c.State != WorkerConnected {
+				report.Ready = false
+			}
+		}
+		report.Tasks = append(report.Tasks, t)
+	}
+
+	organizes = organizes[:0]
+	for k := range Manage.polls {
+		organizes = append(organizes, string(k))
+	}
+	sort.Strings(organizes)
+
+	for _, k := range organizes {
+		p := Manage.polls[Organize(k)].status()
+		if !p.healthy() {
+			report.Ready = false
+		}
+		report.Polls = append(report.Polls, p)
+	}
+
+	return report
+}
+
+//轮询状态
+func (p *poller) status() *PollStatus {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	s := &PollStatus{
+		Organize: p.Organize,
+		Topics:   make([]string, 0, len(p.topics)),
+		LastPoll: p.polled,
+		Failures: p.failures,
+	}
+	for k := range p.topics {
+		s.Topics = append(s.Topics, k)
+	}
+	if p.lastErr != nil {
+		s.LastError = p.lastErr.Error()
+	}
+	sort.Strings(s.Topics)
+
+	return s
+}
+
+//worker集合状态
+func (g *workerGroup) status() *TaskStatus {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	t := &TaskStatus{
+		Organize: g.Organize,
+		Streams:  g.seqs.status(),
+	}
+	for _, w := range g.workers {
+		t.Conns = append(t.Conns, w.status())
+	}
+
+	return t
+}
+
+//连接状态
+func (w *Worker) status() *ConnStatus {
+	w.subLock.Lock()
+	defer w.subLock.Unlock()
+
+	c := &ConnStatus{
+		Id:         w.id,
+		State:      w.Status,
+		Reconnects: atomic.LoadInt64(&w.reconnects),
+		Pending:    make([]string, 0, len(w.Subscribing)),
+		Confirmed:  make([]string, 0, len(w.Subscribes)),
+	}
+	if last := atomic.LoadInt64(&w.lastMessage); last != 0 {
+		c.LastMessage = time.Unix(0, last)
+	}
+	for k := range w.Subscribing {
+		c.Pending = append(c.Pending, k)
+	}
+	for k := range w.Subscribes {
+		c.Confirmed = append(c.Confirmed, k)
+	}
+	sort.Strings(c.Pending)
+	sort.Strings(c.Confirmed)
+
+	return c
+}
+
+//数据流状态
+//只返回推送过数据的数据流
+func (s *sequences) status() []*StreamStatus {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	streams := make([]*StreamStatus, 0, len(s.data))
+	for _, st := range s.data {
+		if st.local == 0 {
+			continue
+		}
+
+		streams = append(streams, &StreamStatus{
+			Stream:     st.stream,
+			Symbol:     st.symbol,
+			Seq:        st.local,
+			LastUpdate: st.updated,
+			Temporize:  st.latency,
+		})
+	}
+	sort.Slice(streams, func(i, j int) bool {
+		if streams[i].Symbol != streams[j].Symbol {
+			return streams[i].Symbol < streams[j].Symbol
+		}
+		return streams[i].Stream < streams[j].Stream
+	})
+
+	return streams
+}
@@ -2,28 +2,52 @@ package market
 
 import (
 	"context"
+	"errors"
 	"github.com/gorilla/websocket"
 	"github.com/zhaocong6/goUtils/chanlock"
-	"log"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+//task连接状态
+type WorkerState string
+
+//task连接中状态
+const WorkerConnecting WorkerState = "connecting"
+
 //task运行中状态
-const runIng = 1
+const WorkerConnected WorkerState = "connected"
+
+//task断线重连中状态
+const WorkerReconnecting WorkerState = "reconnecting"
+
+//task停止状态
+const WorkerStopped WorkerState = "stopped"
 
 //worker list gc时间
 const workerListGcTime = 2
 
+//ws没有连接时写入数据
+var errNotConnected = errors.New("ws未连接")
+
+//超过规定时间没有收到ping或者pong
+var errPingTimeout = errors.New("pingpong超时")
+
+//ws数据不是推送数据, 例如pong和订阅返回
+var errNotPush = errors.New("不是推送数据")
+
 type (
 
 	//各个交易所handle接口
 	Handler interface {
-		formatSubscribeHandle(*Subscriber) []byte                //格式化订阅消息, 转化成统一的sub
-		pingPongHandle(*Worker)                                  //ping pong机制
-		formatMsgHandle(int, []byte, *Worker) (*Marketer, error) //处理ws返回数据
-		subscribed(msg []byte, worker *Worker)                   //处理订阅成功后的业务
+		formatSubscribeHandle(*Subscriber) (string, []byte) //格式化订阅消息, 返回订阅主题和统一的sub
+		formatUnsubscribeHandle(topic string) []byte        //格式化取消订阅消息
+		pingPongHandle(*Worker)                             //ping pong机制
+		decodeMsgHandle(int, []byte) ([]byte, error)        //解压ws返回数据, 不处理的数据返回nil
+		formatMsgHandle([]byte, *Worker) (Eventer, error)   //处理解压后的ws返回数据
+		subscribed(msg []byte, worker *Worker)              //处理订阅成功后的业务
 	}
 
 	//worker基础
@@ -31,34 +55,99 @@ type (
 		ctx              context.Context   //context
 		wsUrl            string            //ws地址
 		Organize         Organize          //交易所
-		Status           int               //状态
-		LastRunTimestamp time.Duration     //最后运行时间
+		Status           WorkerState       //状态, 使用subLock
+		LastRunTimestamp time.Time         //最后运行时间
 		WsConn           *websocket.Conn   //ws连接
-		Subscribing      map[string][]byte //订阅中数据
-		Subscribes       map[string][]byte //订阅成功数据
+		Subscribing      map[string][]byte //订阅中数据, key为订阅主题
+		Subscribes       map[string][]byte //订阅成功数据, key为订阅主题
 		subLock          sync.Mutex
 		List             *Lister           //订阅成功返回后的行情数据list
 		handler          Handler           //handel接口
 		redialLock       chanlock.ChanLock //重连并发锁
 		wsWriteLock      sync.Mutex        //msg写入并发锁
+		readSeq          uint64            //ws数据读取序号
+		sequencer        *sequencer        //按读取序号处理数据
+		seqs             *sequences        //每个数据流的序号
+		group            *workerGroup      //所属的worker集合
+		id               int               //连接在集合中的编号
+		lastMessage      int64             //最后收到ws数据的unix时间(纳秒), 原子操作
+		reconnects       int64             //重连次数, 原子操作
+	}
+
+	//需要按读取顺序合并的数据
+	//例如增量深度, 合并后返回完整的数据
+	merger interface {
+		merge(*Worker) (Eventer, error)
+	}
+
+	//保存订阅主题本地状态的handler
+	//取消订阅和断线后清理, 例如okex本地深度
+	topicResetter interface {
+		resetTopic(topic string) //清理一个主题
+		resetTopics()            //清理所有主题
+	}
+
+	//按读取序号依次执行
+	//ws数据并行解析, 合并和推送按读取顺序执行
+	sequencer struct {
+		next uint64
+		cond *sync.Cond
 	}
 
 	coJob struct {
-		w       *Worker
-		msgType int
-		msg     []byte
+		w        *Worker
+		seq      uint64
+		msgType  int
+		msg      []byte
+		received time.Time //本地接收时间
 	}
 )
 
+func newSequencer() *sequencer {
+	return &sequencer{
+		cond: sync.NewCond(&sync.Mutex{}),
+	}
+}
+
+//等待轮到该序号
+func (s *sequencer) wait(seq uint64) {
+	s.cond.L.Lock()
+	for s.next != seq {
+		s.cond.Wait()
+	}
+}
+
+//该序号执行完成, 唤醒下一个序号
+func (s *sequencer) done() {
+	s.next++
+	s.cond.L.Unlock()
+	s.cond.Broadcast()
+}
+
 //运行task
 //ws连接
 //数据监听
+//context关闭后关闭连接, 结束监听
 func (w *Worker) RunTask() {
-	log.Printf("%s 服务启动", w.Organize)
-	w.WsConn, _ = dial(w.wsUrl)
+	logger().Info("服务启动", "organize", w.Organize, "conn", w.id)
 	defer func() {
-		w.WsConn.Close()
-		log.Printf("%s 服务关闭", w.Organize)
+		w.setStatus(WorkerStopped)
+		logger().Info("服务关闭", "organize", w.Organize, "conn", w.id)
+	}()
+
+	conn, err := w.dial()
+	if err != nil {
+		logger().Error("连接失败", "organize", w.Organize, "conn", w.id, "err", err)
+		return
+	}
+	w.setConn(conn)
+	w.setStatus(WorkerConnected)
+	hooks().connect(w)
+	w.subscribePending()
+
+	go func() {
+		<-w.ctx.Done()
+		w.closeConn()
 	}()
 
 	w.listenHandle()
@@ -70,37 +159,104 @@ var DefaultDialer = &websocket.Dialer{
 }
 
 //ws连接
-//失败后3秒重新连接
-//直到连接成功
-func dial(u string) (*websocket.Conn, error) {
-	log.Printf("%s 连接中.", u)
+//失败后按DefaultBackoff等待重新连接
+//直到连接成功, 超过最大重试次数或者context关闭
+//每次尝试都推送重连事件
+func (w *Worker) dial() (*websocket.Conn, error) {
+	logger().Info("连接中", "organize", w.Organize, "conn", w.id, "url", w.wsUrl)
+
+	for attempt := 1; ; attempt++ {
+		conn, _, err := DefaultDialer.DialContext(w.ctx, w.wsUrl, nil)
+		if err == nil {
+			logger().Info("连接成功", "organize", w.Organize, "conn", w.id, "url", w.wsUrl)
+			w.reconnectEvent(ConnConnected, attempt, 0, nil)
+			return conn, nil
+		}
+
+		logger().Warn("连接失败", "organize", w.Organize, "conn", w.id, "attempt", attempt, "err", err)
+		if w.ctx.Err() != nil || (DefaultBackoff.MaxAttempts > 0 && attempt >= DefaultBackoff.MaxAttempts) {
+			w.reconnectEvent(ConnFailed, attempt, 0, err)
+			return nil, err
+		}
+
+		delay := DefaultBackoff.delay(attempt)
+		w.reconnectEvent(ConnRetrying, attempt, delay, err)
+
+		select {
+		case <-w.ctx.Done():
+			w.reconnectEvent(ConnFailed, attempt, 0, w.ctx.Err())
+			return nil, w.ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
 
-RETRY:
-	conn, _, err := DefaultDialer.Dial(u, nil)
+//推送重连事件
+func (w *Worker) reconnectEvent(state ConnState, attempt int, delay time.Duration, err error) {
+	e := &Reconnect{
+		Event: Event{
+			Type:      ReconnectEvent,
+			Organize:  w.Organize,
+			Timestamp: time.Now(),
+		},
+		State:   state,
+		Attempt: attempt,
+		Delay:   delay,
+	}
 	if err != nil {
-		time.Sleep(time.Second * 3)
-		log.Println(err)
-		goto RETRY
+		e.Err = err.Error()
 	}
 
-	log.Printf("%s 连接成功.", u)
-	return conn, nil
+	w.group.out.event(e)
+}
+
+//设置连接状态
+func (w *Worker) setStatus(status WorkerState) {
+	w.subLock.Lock()
+	defer w.subLock.Unlock()
+
+	w.Status = status
+}
+
+//替换ws连接
+func (w *Worker) setConn(conn *websocket.Conn) {
+	w.wsWriteLock.Lock()
+	defer w.wsWriteLock.Unlock()
+
+	w.WsConn = conn
+}
+
+//关闭ws连接
+func (w *Worker) closeConn() {
+	w.wsWriteLock.Lock()
+	defer w.wsWriteLock.Unlock()
+
+	if w.WsConn != nil {
+		w.WsConn.Close()
+	}
 }
 
 func (w *Worker) writeMessage(messageType int, data []byte) error {
 	w.wsWriteLock.Lock()
 	defer w.wsWriteLock.Unlock()
 
+	if w.WsConn == nil {
+		return errNotConnected
+	}
 	return w.WsConn.WriteMessage(messageType, data)
 }
 
 //发送订阅
+//失败时记录日志, 由重新订阅协程或者重连再次发送
+//没有连接时不发送, 连接成功后发送
 func (w *Worker) Subscribe(msg []byte) error {
-	if w.WsConn != nil {
-		err := w.writeMessage(websocket.TextMessage, msg)
-		if err != nil {
-			return err
-		}
+	err := w.writeMessage(websocket.TextMessage, msg)
+	if err == errNotConnected {
+		return nil
+	}
+	if err != nil {
+		logger().Warn("发送订阅失败", "organize", w.Organize, "conn", w.id, "msg", string(msg), "err", err)
+		return err
 	}
 
 	return nil
@@ -108,8 +264,9 @@ func (w *Worker) Subscribe(msg []byte) error {
 
 //关闭连接
 //重新创建一个连接
-//发送订阅
-func (w *Worker) closeRedialSub() error {
+//重新分配订阅后发送订阅
+//cause为断开原因
+func (w *Worker) closeRedialSub(cause error) error {
 	if w.redialLock.TryLock(time.Millisecond) == false {
 		return nil
 	}
@@ -118,56 +275,210 @@ func (w *Worker) closeRedialSub() error {
 		w.redialLock.Unlock()
 	}()
 
-	log.Printf("%s 断线重连", w.Organize)
+	logger().Warn("断线重连", "organize", w.Organize, "conn", w.id, "err", cause)
 
-	var err error
-	w.WsConn.Close()
-	w.WsConn, err = dial(w.wsUrl)
+	w.setStatus(WorkerReconnecting)
+	reconnects := atomic.AddInt64(&w.reconnects, 1)
+	metrics().reconnect(w.Organize)
+	hooks().disconnect(w, cause)
+	w.closeConn()
+	if r, ok := w.handler.(topicResetter); ok {
+		r.resetTopics()
+	}
+	conn, err := w.dial()
+	if err != nil {
+		logger().Error("重连失败", "organize", w.Organize, "conn", w.id, "err", err)
+		return err
+	}
+	w.setConn(conn)
+	w.setStatus(WorkerConnected)
+	hooks().connect(w)
+	hooks().reconnect(w, reconnects)
+	w.seqs.gaps(GapReconnect, w)
 
 	w.subLock.Lock()
-	defer w.subLock.Unlock()
-
 	for k, v := range w.Subscribes {
 		w.Subscribing[k] = v
 		delete(w.Subscribes, k)
-		w.Subscribe(w.Subscribing[k])
+	}
+	w.subLock.Unlock()
+
+	if w.group != nil {
+		w.group.rebalance(w)
+	}
+
+	w.subLock.Lock()
+	defer w.subLock.Unlock()
+
+	for _, v := range w.Subscribing {
+		w.Subscribe(v)
 	}
 	return err
 }
 
-//处理订阅数据格式
-//订阅
-func (w *Worker) subscribeHandle(s *Subscriber) {
+//订阅一个主题
+func (w *Worker) subscribeTopic(topic string, sub []byte) {
 	w.subLock.Lock()
 	defer w.subLock.Unlock()
 
-	w.Subscribing[s.Symbol] = w.handler.formatSubscribeHandle(s)
-	w.Subscribe(w.Subscribing[s.Symbol])
+	w.Subscribing[topic] = sub
+	w.Subscribe(sub)
+}
+
+//取消订阅一个主题
+//没有订阅该主题时不发送
+func (w *Worker) unsubscribeTopic(topic string) {
+	w.subLock.Lock()
+	defer w.subLock.Unlock()
+
+	_, ing := w.Subscribing[topic]
+	_, ed := w.Subscribes[topic]
+	if !ing && !ed {
+		return
+	}
+
+	delete(w.Subscribing, topic)
+	delete(w.Subscribes, topic)
+	w.Subscribe(w.handler.formatUnsubscribeHandle(topic))
+	if r, ok := w.handler.(topicResetter); ok {
+		r.resetTopic(topic)
+	}
+}
+
+//是否已经订阅该主题
+func (w *Worker) hasTopic(topic string) bool {
+	w.subLock.Lock()
+	defer w.subLock.Unlock()
+
+	_, ing := w.Subscribing[topic]
+	_, ed := w.Subscribes[topic]
+	return ing || ed
+}
+
+//该主题是否已经订阅成功
+func (w *Worker) confirmed(topic string) bool {
+	w.subLock.Lock()
+	defer w.subLock.Unlock()
+
+	_, ok := w.Subscribes[topic]
+	return ok
+}
+
+//订阅中和订阅成功的主题数量
+func (w *Worker) topicCount() int {
+	w.subLock.Lock()
+	defer w.subLock.Unlock()
+
+	return len(w.Subscribing) + len(w.Subscribes)
+}
+
+//重新订阅一个已经订阅成功的主题
+//先取消订阅, 等待订阅成功返回
+func (w *Worker) resubscribeTopic(topic string) bool {
+	w.subLock.Lock()
+	defer w.subLock.Unlock()
+
+	sub, ok := w.Subscribes[topic]
+	if !ok {
+		return false
+	}
+
+	delete(w.Subscribes, topic)
+	w.Subscribing[topic] = sub
+	w.Subscribe(w.handler.formatUnsubscribeHandle(topic))
+	w.Subscribe(sub)
+	return true
+}
+
+//取出超过keep数量的订阅中主题
+//重连时分配到其他连接
+func (w *Worker) takeExcess(keep int) map[string][]byte {
+	w.subLock.Lock()
+	defer w.subLock.Unlock()
+
+	excess := make(map[string][]byte)
+	n := len(w.Subscribes)
+	for k, v := range w.Subscribing {
+		if n < keep {
+			n++
+			continue
+		}
+		excess[k] = v
+		delete(w.Subscribing, k)
+	}
+	return excess
 }
 
 //处理订阅成功
-func (w *Worker) subscribed(symbol string) {
+func (w *Worker) subscribed(topic string) {
+	w.subLock.Lock()
+	sub, ok := w.Subscribing[topic]
+	if ok {
+		w.Subscribes[topic] = sub
+		delete(w.Subscribing, topic)
+	}
+	w.subLock.Unlock()
+
+	if ok {
+		s := w.subscriberOf(topic)
+		hooks().subscribed(s.Organize, s.Symbol, topic)
+	}
+}
+
+//处理交易所返回的订阅失败
+//主题保留在Subscribing中, 由重新订阅协程再次发送
+func (w *Worker) subscribeFailed(topic string, err error) {
+	s := w.subscriberOf(topic)
+	logger().Warn("订阅失败", "organize", s.Organize, "conn", w.id, "symbol", s.Symbol, "topic", topic, "err", err)
+	hooks().subscribeFailed(s.Organize, s.Symbol, topic, err)
+}
+
+//订阅主题对应的订阅
+//没有记录时只返回交易所
+func (w *Worker) subscriberOf(topic string) *Subscriber {
+	if w.group != nil {
+		if s := w.group.subscriber(topic); s != nil {
+			return s
+		}
+	}
+	return &Subscriber{Organize: w.Organize}
+}
+
+//返回订阅中的主题
+func (w *Worker) subscribingTopics() []string {
 	w.subLock.Lock()
 	defer w.subLock.Unlock()
 
-	if sub, ok := w.Subscribing[symbol]; ok {
-		w.Subscribes[symbol] = sub
-		delete(w.Subscribing, symbol)
+	topics := make([]string, 0, len(w.Subscribing))
+	for k := range w.Subscribing {
+		topics = append(topics, k)
 	}
+	return topics
 }
 
 //重新订阅Subscribing中的数据
 func (w *Worker) resubscribeHandle() {
 	for {
 		select {
+		case <-w.ctx.Done():
+			return
 		case <-time.NewTimer(time.Second * 5).C:
-			for _, sub := range w.Subscribing {
-				w.Subscribe(sub)
-			}
+			w.subscribePending()
 		}
 	}
 }
 
+//发送Subscribing中的订阅
+//连接成功前加入的订阅在连接成功后立即发送
+func (w *Worker) subscribePending() {
+	w.subLock.Lock()
+	defer w.subLock.Unlock()
+
+	for _, sub := range w.Subscribing {
+		w.Subscribe(sub)
+	}
+}
+
 //监听
 //创建ping pong事件处理协程
 //创建重新订阅事件协程
@@ -185,7 +496,8 @@ func (w *Worker) listenHandle() {
 			//等待ws数据
 			msgType, msg, err := w.WsConn.ReadMessage()
 			if err != nil {
-				err = w.closeRedialSub()
+				logger().Warn("读取数据失败", "organize", w.Organize, "conn", w.id, "err", err)
+				err = w.closeRedialSub(err)
 				if err != nil {
 					return
 				}
@@ -193,11 +505,17 @@ func (w *Worker) listenHandle() {
 				continue
 			}
 
+			received := time.Now()
+			atomic.StoreInt64(&w.lastMessage, received.UnixNano())
+			metrics().queue(1)
 			Manage.pool.Put(&coJob{
-				w:       w,
-				msgType: msgType,
-				msg:     msg,
+				w:        w,
+				seq:      w.readSeq,
+				msgType:  msgType,
+				msg:      msg,
+				received: received,
 			})
+			w.readSeq++
 		}
 	}
 }
@@ -206,24 +524,123 @@ func (w *Worker) listenHandle() {
 func (w *Worker) workerListGc() {
 	for {
 		select {
+		case <-w.ctx.Done():
+			return
 		case <-time.NewTimer(workerListGcTime * time.Second).C:
-			w.List.gc(workerListGcTime * time.Second)
+			w.List.gc(time.Now(), workerListGcTime*time.Second)
 		}
 	}
 }
 
 func (c coJob) Handle() error {
-	data, err := c.w.handler.formatMsgHandle(c.msgType, c.msg, c.w)
+	metrics().queue(-1)
+	var data Eventer
+	msg, err := c.w.handler.decodeMsgHandle(c.msgType, c.msg)
+	decoded := err == nil && msg != nil
+	if decoded {
+		data, err = c.w.handler.formatMsgHandle(msg, c.w)
+	}
+
+	c.w.sequencer.wait(c.seq)
+	defer c.w.sequencer.done()
+
+	//按读取顺序记录解压后的数据
+	offset := ClockOffset(c.w.Organize)
+	if decoded {
+		recorder().record(c.w, c.received, offset, msg)
+	}
+
+	return c.w.dispatch(data, err, c.received, offset, c.msg)
+}
+
+//推送到list和pool之外的下游
+//深度行情记录, 归档, ws转发, grpc和消息总线推送
+func publish(data Eventer) {
+	recorder().save(data)
+	archiver().save(data)
+	gateway().publish(data)
+	grpcServer().publish(data)
+	publisher().push(data)
+}
+
+//处理后数据的输出目标
+//实时数据写入全局pool并推送到下游, 回放写入自己的pool, 不推送
+type output struct {
+	markets *writeMarketer
+	events  *writeEventer
+	live    bool //实时数据, 调用publish推送到下游, 记录指标和调用回调
+}
+
+//实时数据的输出目标
+var liveOutput = &output{
+	markets: &writeMarketPool.writeMarketer,
+	events:  writeEventPool,
+	live:    true,
+}
+
+//输出深度行情
+func (o *output) market(m *Marketer) {
+	if o.live {
+		publish(m)
+	}
+	o.markets.writeRingBuffer(m)
+}
+
+//输出深度行情以外的事件
+func (o *output) event(e Eventer) {
+	if o.live {
+		publish(e)
+	}
+	o.events.writeRingBuffer(e)
+}
+
+//处理解析后的数据
+//合并增量数据, 记录延迟, 写入list和pool
+//调用方需要保证按读取顺序调用, offset为接收时的交易所时钟偏移, raw为解析失败时回调的原始数据
+//回放数据不记录指标, 不调用回调
+func (w *Worker) dispatch(data Eventer, err error, received time.Time, offset time.Duration, raw []byte) error {
+	live := w.group.out.live
+	if m, ok := data.(merger); ok && err == nil {
+		data, err = m.merge(w)
+	}
 	if err != nil {
+		logger().Warn("解析数据失败", "organize", w.Organize, "conn", w.id, "err", err)
+		if live {
+			metrics().decodeError(w.Organize)
+			hooks().decodeError(w.Organize, raw, err)
+		}
 		return err
 	}
+	if m, ok := data.(*Marketer); ok {
+		m.stamp(received, offset)
+	}
+	if live {
+		metrics().message(w.Organize, data)
+	}
 
-	//拷贝两份指针
+	//深度行情拷贝两份指针
 	//list用于被动查询
 	//pool用于主动通信
-	if data != nil {
-		c.w.List.Add(data.Symbol, data)
-		writeMarketPool.writeRingBuffer(data)
+	//其他事件写入event pool
+	switch e := data.(type) {
+	case nil:
+	case *Marketer:
+		if !w.seqs.next(e, w) {
+			return nil
+		}
+		e.trimDepth(w.group.depthLevel(e.topic))
+		w.List.Add(e.Symbol, e)
+		w.group.out.market(e)
+	case eventBatch:
+		for _, v := range e {
+			if w.seqs.next(v, w) {
+				w.group.out.event(v)
+			}
+		}
+	default:
+		if w.seqs.next(e, w) {
+			w.group.out.event(e)
+		}
 	}
 	return nil
 }
@@ -0,0 +1,130 @@
+package market
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+//创建连接到模拟交易所的worker集合
+//等待连接成功后返回
+func newMockGroup(t *testing.T, m *mockExchange, newWorker func(context.Context) *Worker) *workerGroup {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	g := newWorkerGroup(ctx, newWorker)
+	g.setWsUrl(m.url())
+	g.RunTask()
+
+	for deadline := time.Now().Add(5 * time.Second); g.workers[0].status().State != WorkerConnected; {
+		if time.Now().After(deadline) {
+			t.Fatal("连接模拟交易所失败")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return g
+}
+
+func TestWorker_OkExDepth(t *testing.T) {
+	m := newMockExchange(t, OkEx)
+	g := newMockGroup(t, m, newOkEx)
+
+	g.subscribeHandle(&Subscriber{
+		Symbol:     "ETH-USDT",
+		Organize:   OkEx,
+		MarketType: SpotMarket,
+	})
+	m.waitSub(t, "spot/depth5:ETH-USDT")
+
+	m.push(`{"table":"spot/depth5","data":[{"asks":[["8.8","3"],["8.9","7"]],"bids":[["8.75","1"],["8.6","5"]],"instrument_id":"ETH-USDT","timestamp":"2020-03-01T08:00:00.123Z"}]}`)
+
+	e := waitMarketer(t, "ETH-USDT")
+	if e.BuyFirst != "8.75" || e.SellFirstSize != "3" || e.Timestamp.UnixNano() != 1583049600123e6 {
+		t.Fatal(e)
+	}
+	if g.List.Find("ETH-USDT").ToMap()["ETH-USDT"] != e {
+		t.Fatal("list没有更新")
+	}
+}
+
+func TestWorker_HuoBiDepth(t *testing.T) {
+	m := newMockExchange(t, HuoBi)
+	g := newMockGroup(t, m, newHuoBi)
+
+	g.subscribeHandle(&Subscriber{
+		Symbol:     "ethusdt",
+		Organize:   HuoBi,
+		MarketType: SpotMarket,
+	})
+	m.waitSub(t, "market.ethusdt.depth.step1")
+
+	ts := strconv.FormatInt(time.Now().UnixNano()/1e6, 10)
+	m.push(`{"ch":"market.ethusdt.depth.step1","ts":` + ts + `,"tick":{"bids":[[230.1,2]],"asks":[[230.2,1.5]],"version":100}}`)
+
+	e := waitMarketer(t, "ethusdt")
+	if e.BuyFirst != "230.1" || e.SellFirstSize != "1.5" || e.ExchangeSeq != 100 {
+		t.Fatal(e)
+	}
+}
+
+func TestWorker_Reconnect(t *testing.T) {
+	m := newMockExchange(t, OkEx)
+	g := newMockGroup(t, m, newOkEx)
+
+	g.subscribeHandle(&Subscriber{
+		Symbol:     "BTC-USDT",
+		Organize:   OkEx,
+		MarketType: SpotMarket,
+	})
+	m.waitSub(t, "spot/depth5:BTC-USDT")
+
+	m.disconnect()
+	m.waitSub(t, "spot/depth5:BTC-USDT")
+
+	w := g.workers[0]
+	if w.status().Reconnects != 1 {
+		t.Fatal(w.status())
+	}
+	waitEvent(t, func(e Eventer) bool {
+		r, ok := e.(*Reconnect)
+		return ok && r.State == ConnConnected
+	})
+
+	m.push(`{"table":"spot/depth5","data":[{"asks":[["9000.1","1"]],"bids":[["9000","2"]],"instrument_id":"BTC-USDT","timestamp":"2020-03-01T08:00:00.123Z"}]}`)
+	if e := waitMarketer(t, "BTC-USDT"); e.BuyFirst != "9000" {
+		t.Fatal(e)
+	}
+}
+
+func TestWorker_SubscribeFailed(t *testing.T) {
+	m := newMockExchange(t, HuoBi)
+	m.reject["market.xxxusdt.depth.step1"] = true
+
+	failed := make(chan string, 1)
+	SetHooks(&Hooks{
+		OnSubscribeFailed: func(organize Organize, symbol, topic string, err error) {
+			select {
+			case failed <- symbol:
+			default:
+			}
+		},
+	})
+	defer SetHooks(nil)
+
+	g := newMockGroup(t, m, newHuoBi)
+	g.subscribeHandle(&Subscriber{
+		Symbol:     "xxxusdt",
+		Organize:   HuoBi,
+		MarketType: SpotMarket,
+	})
+
+	select {
+	case symbol := <-failed:
+		if symbol != "xxxusdt" {
+			t.Fatal(symbol)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("没有收到订阅失败")
+	}
+}
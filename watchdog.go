@@ -0,0 +1,141 @@
+package market
+
+import (
+	"encoding/json"
+	"time"
+)
+
+//数据流超过这个时间没有推送视为停止推送
+//只对深度, 最优买卖价, 标记价格和指数价格生效, 0表示不检测
+var StaleThreshold = 30 * time.Second
+
+//watchdog检测间隔
+const watchdogInterval = 5 * time.Second
+
+//需要检测的订阅主题
+type watch struct {
+	stream   EventType     //数据类型
+	organize Organize      //交易所
+	symbol   string        //合约或者币对
+	quiet    time.Duration //超过这个时间没有推送视为停止推送
+}
+
+//数据流停止推送事件
+//推送后会自动重新订阅
+type Stale struct {
+	Event
+	Stream EventType     `json:"stream"` //停止推送的数据类型
+	Quiet  time.Duration `json:"quiet"`  //没有推送的时间
+}
+
+//按JsonMillisecond序列化
+func (e *Stale) MarshalJSON() ([]byte, error) {
+	type alias Stale
+	if !JsonMillisecond {
+		return json.Marshal((*alias)(e))
+	}
+
+	return json.Marshal(&struct {
+		*alias
+		Timestamp int64 `json:"timestamp,omitempty"`
+		Quiet     int64 `json:"quiet"`
+	}{
+		alias:     (*alias)(e),
+		Timestamp: millisecond(e.Timestamp),
+		Quiet:     e.Quiet.Milliseconds(),
+	})
+}
+
+//订阅的检测时间
+//订阅指定StaleAfter时使用订阅的时间, 小于0不检测
+func staleAfter(s *Subscriber) time.Duration {
+	if s.StaleAfter != 0 {
+		return s.StaleAfter
+	}
+
+	switch s.DataType {
+	case DepthData, BBOData, MarkPriceData, IndexPriceData:
+		return StaleThreshold
+	}
+	return 0
+}
+
+//记录需要检测的订阅主题
+//调用方需要持有lock
+func (g *workerGroup) watch(topic string, s *Subscriber) {
+	quiet := staleAfter(s)
+	if quiet <= 0 {
+		delete(g.watches, topic)
+		return
+	}
+
+	w := &watch{
+		stream:   s.DataType.eventType(),
+		organize: s.Organize,
+		symbol:   s.Symbol,
+		quiet:    quiet,
+	}
+	g.watches[topic] = w
+	g.seqs.touch(w.organize, w.stream, w.symbol)
+}
+
+//停止推送的主题
+type staleTopic struct {
+	topic string
+	watch *watch
+	quiet time.Duration
+}
+
+//定时检测订阅成功的主题
+//超过检测时间没有推送的主题重新订阅, 并推送停止推送事件
+func (g *workerGroup) watchdog() {
+	for {
+		select {
+		case <-g.ctx.Done():
+			return
+		case <-time.NewTimer(watchdogInterval).C:
+			g.checkStale()
+		}
+	}
+}
+
+//检测一次停止推送的主题
+//持有lock时只收集主题, 释放后再重新订阅, 调用回调和推送事件, 回调中可以再调用订阅
+func (g *workerGroup) checkStale() {
+	var stales []staleTopic
+	g.lock.Lock()
+	for topic, v := range g.watches {
+		quiet := time.Since(g.seqs.updated(v.organize, v.stream, v.symbol))
+		if quiet > v.quiet {
+			stales = append(stales, staleTopic{topic: topic, watch: v, quiet: quiet})
+		}
+	}
+	workers := append([]*Worker(nil), g.workers...)
+	g.lock.Unlock()
+
+	for _, v := range stales {
+		resubscribed := false
+		for _, w := range workers {
+			if w.resubscribeTopic(v.topic) {
+				resubscribed = true
+			}
+		}
+		if !resubscribed {
+			continue
+		}
+
+		logger().Warn("停止推送, 重新订阅", "organize", g.Organize, "topic", v.topic, "quiet", v.quiet)
+		g.seqs.touch(v.watch.organize, v.watch.stream, v.watch.symbol)
+		hooks().stale(v.watch.organize, v.watch.symbol, v.watch.stream, v.quiet)
+		g.out.event(&Stale{
+			Event: Event{
+				Type:      StaleEvent,
+				Organize:  v.watch.organize,
+				Symbol:    v.watch.symbol,
+				Timestamp: time.Now(),
+			},
+			Stream: v.watch.stream,
+			Quiet:  v.quiet,
+		})
+	}
+}
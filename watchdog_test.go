@@ -0,0 +1,71 @@
+package market
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStaleAfter(t *testing.T) {
+	if staleAfter(&Subscriber{DataType: DepthData}) != StaleThreshold {
+		t.Fatal("depth should use StaleThreshold")
+	}
+	if staleAfter(&Subscriber{DataType: LiquidationData}) != 0 {
+		t.Fatal("liquidation should not be watched by default")
+	}
+	if staleAfter(&Subscriber{DataType: FundingRateData, StaleAfter: time.Minute}) != time.Minute {
+		t.Fatal("StaleAfter should override default")
+	}
+}
+
+func TestWorker_ResubscribeTopic(t *testing.T) {
+	g := newWorkerGroup(context.Background(), newOkEx)
+	s := &Subscriber{Symbol: "BTC-USDT", Organize: OkEx, MarketType: SpotMarket}
+	g.subscribeHandle(s)
+
+	w := g.workers[0]
+	if w.resubscribeTopic("spot/depth5:BTC-USDT") {
+		t.Fatal("topic not confirmed yet")
+	}
+
+	w.subscribed("spot/depth5:BTC-USDT")
+	if !w.resubscribeTopic("spot/depth5:BTC-USDT") {
+		t.Fatal("confirmed topic should resubscribe")
+	}
+	if _, ok := w.Subscribing["spot/depth5:BTC-USDT"]; !ok {
+		t.Fatal("topic should be pending again")
+	}
+}
+
+func TestWorkerGroup_CheckStaleHook(t *testing.T) {
+	g := newWorkerGroup(context.Background(), newOkEx)
+	s := &Subscriber{Symbol: "BTC-USDT", Organize: OkEx, MarketType: SpotMarket, StaleAfter: time.Nanosecond}
+	g.subscribeHandle(s)
+	g.workers[0].subscribed("spot/depth5:BTC-USDT")
+	time.Sleep(time.Millisecond)
+
+	//回调中订阅不能死锁
+	stale := make(chan EventType, 1)
+	SetHooks(&Hooks{OnStale: func(organize Organize, symbol string, stream EventType, quiet time.Duration) {
+		g.subscribeHandle(&Subscriber{Symbol: "ETH-USDT", Organize: OkEx, MarketType: SpotMarket})
+		stale <- stream
+	}})
+	defer SetHooks(nil)
+
+	done := make(chan struct{})
+	go func() {
+		g.checkStale()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("checkStale deadlock")
+	}
+	if stream := <-stale; stream != DepthEvent {
+		t.Fatal(stream)
+	}
+	if !g.workers[0].hasTopic("spot/depth5:ETH-USDT") {
+		t.Fatal("hook subscribe lost")
+	}
+}
@@ -2,60 +2,116 @@ package market
 
 import (
 	"context"
+	"errors"
 	"github.com/zhaocong6/goUtils/goroutinepool"
-	"log"
 	"runtime/debug"
+	"sort"
+	"sync/atomic"
 )
 
 //manage结构体
 //用于管理task任务, 和关闭task运行任务
 //使用context通信
 var Manage struct {
-	tasks  map[Organize]*Worker
-	Ctx    context.Context
-	Cancel context.CancelFunc
-	pool   *goroutinepool.Worker
+	tasks     map[Organize]*workerGroup
+	polls     map[Organize]*poller
+	Ctx       context.Context
+	Cancel    context.CancelFunc
+	pool      *goroutinepool.Worker
+	logger    atomic.Value //日志, 使用SetLogger设置
+	hooks     atomic.Value //生命周期回调, 使用SetHooks设置
+	record    atomic.Value //原始数据记录, 使用SetRecorder设置
+	archive   atomic.Value //行情数据归档, 使用SetArchive设置
+	gateway   atomic.Value //ws转发服务, 使用SetGateway设置
+	grpc      atomic.Value //grpc服务, 使用SetGrpcServer设置
+	publisher atomic.Value //消息总线推送, 使用SetPublisher设置
+	metrics   atomic.Value //prometheus指标, 使用RegisterMetrics设置
 }
 
 func init() {
-	Manage.Ctx, Manage.Cancel = context.WithCancel(context.Background())
-
 	Manage.pool = goroutinepool.NewPool(goroutinepool.Options{
 		Capacity:  20,
 		JobBuffer: 500,
 	})
 
-	Manage.tasks = map[Organize]*Worker{}
-	Manage.tasks[OkEx] = newOkEx(Manage.Ctx)
-	Manage.tasks[HuoBi] = newHuoBi(Manage.Ctx)
+	initTasks()
+}
+
+//创建context和所有worker集合, 轮询任务
+//Close之后context不能再使用, 重新运行前需要重新创建
+func initTasks() {
+	Manage.Ctx, Manage.Cancel = context.WithCancel(context.Background())
+	Manage.tasks = newWorkerGroups(Manage.Ctx)
+
+	Manage.polls = map[Organize]*poller{}
+	Manage.polls[OkEx] = newPoller(Manage.Ctx, OkEx, &okexPollHandler{})
+	Manage.polls[HuoBi] = newPoller(Manage.Ctx, HuoBi, &huoBiPollHandler{})
+}
+
+//每个ws地址创建连接的方法
+//key为连接的交易所, 和Worker.Organize相同
+var newWorkers = map[Organize]func(context.Context) *Worker{
+	OkEx:               newOkEx,
+	HuoBi:              newHuoBi,
+	huoBiIndex:         newHuoBiIndex,
+	huoBiNotify:        newHuoBiNotify,
+	huoBiFuturesNotify: newHuoBiFuturesNotify,
+}
+
+//创建所有ws地址的worker集合
+func newWorkerGroups(ctx context.Context) map[Organize]*workerGroup {
+	tasks := make(map[Organize]*workerGroup, len(newWorkers))
+	for organize, newWorker := range newWorkers {
+		tasks[organize] = newWorkerGroup(ctx, newWorker)
+	}
+	return tasks
 }
 
 //运行work
+//每个worker集合运行自己的所有连接
 func Run() {
 	for _, t := range Manage.tasks {
+		t.RunTask()
+	}
+
+	for _, p := range Manage.polls {
 
-		go func(t *Worker) {
+		go func(p *poller) {
 
 			defer func() {
 				if err := recover(); err != nil {
-					log.Println(err, string(debug.Stack()))
+					logger().Error("运行异常", "err", err, "stack", string(debug.Stack()))
 				}
 			}()
 
-			t.RunTask()
-		}(t)
+			p.RunTask()
+		}(p)
 	}
 
-	go func() {
+	go syncClocks(Manage.Ctx)
+
+	go func(ctx context.Context) {
 
 		defer func() {
 			if err := recover(); err != nil {
-				log.Println(err, string(debug.Stack()))
+				logger().Error("运行异常", "err", err, "stack", string(debug.Stack()))
 			}
 		}()
 
-		subscribeHandle()
-	}()
+		subscribeHandle(ctx)
+	}(Manage.Ctx)
+}
+
+//替换交易所的ws地址
+//用于测试或者使用代理地址, 需要在Run之前调用
+func SetWsUrl(organize Organize, url string) error {
+	g, ok := Manage.tasks[organize]
+	if !ok {
+		return errors.New("不支持的交易所 " + string(organize))
+	}
+
+	g.setWsUrl(url)
+	return nil
 }
 
 //关闭task
@@ -65,21 +121,86 @@ func Close() {
 }
 
 func Find(organize string, symbol ...string) (m map[string]*Marketer) {
-	switch organize {
-	case "huobi":
-		m = Manage.tasks[HuoBi].List.Find(symbol...).ToMap()
-	case "okex":
-		m = Manage.tasks[OkEx].List.Find(symbol...).ToMap()
+	if l := lister(Organize(organize)); l != nil {
+		m = l.Find(symbol...).ToMap()
 	}
 	return m
 }
 
+//可以查询深度行情的交易所
+var listOrganizes = []Organize{OkEx, HuoBi}
+
+//交易所的行情数据list
+//不支持查询的交易所返回nil
+func lister(organize Organize) *Lister {
+	for _, v := range listOrganizes {
+		if v == organize {
+			return Manage.tasks[organize].List
+		}
+	}
+	return nil
+}
+
+//订阅信息
+type Subscription struct {
+	Organize   Organize   `json:"organize"`              //交易所
+	Symbol     string     `json:"symbol"`                //合约或者币对
+	MarketType MarketType `json:"market_type"`           //交易类型
+	DataType   DataType   `json:"data_type"`             //订阅数据类型
+	DepthLevel DepthLevel `json:"depth_level,omitempty"` //深度档位
+	Topic      string     `json:"topic"`                 //交易所订阅主题
+	Confirmed  bool       `json:"confirmed"`             //交易所已经返回订阅成功
+}
+
+//返回所有ws订阅
+//不包含rest轮询的订阅
+func Subscriptions() []*Subscription {
+	organizes := make([]string, 0, len(Manage.tasks))
+	for k := range Manage.tasks {
+		organizes = append(organizes, string(k))
+	}
+	sort.Strings(organizes)
+
+	var subs []*Subscription
+	for _, k := range organizes {
+		subs = append(subs, Manage.tasks[Organize(k)].subscriptions()...)
+	}
+	return subs
+}
+
+//根据订阅找到对应的worker
+//火币永续的部分数据使用单独的ws地址
+func route(s *Subscriber) *workerGroup {
+	return routeOf(Manage.tasks, s)
+}
+
+//在指定的worker集合中找到订阅对应的worker
+func routeOf(tasks map[Organize]*workerGroup, s *Subscriber) *workerGroup {
+	if s.Organize == HuoBi {
+		return tasks[huoBiRoute(s)]
+	}
+
+	return tasks[s.Organize]
+}
+
 //订阅请求统一处理
-func subscribeHandle() {
+func subscribeHandle(ctx context.Context) {
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case sub := <-readSubscribing:
-			Manage.tasks[sub.Organize].subscribeHandle(sub)
+			if p := pollRoute(sub); p != nil {
+				p.subscribeHandle(sub)
+			} else {
+				route(sub).subscribeHandle(sub)
+			}
+		case sub := <-readUnsubscribing:
+			if p := pollRoute(sub); p != nil {
+				p.unsubscribeHandle(sub)
+			} else {
+				route(sub).unsubscribeHandle(sub)
+			}
 		}
 	}
 }
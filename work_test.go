@@ -1,12 +1,36 @@
 package market
 
 import (
-	"fmt"
 	"testing"
 )
 
-func Test_Run(t *testing.T) {
+//使用新的context和worker集合运行
+//Close会关闭Manage.Ctx, 每次运行重新创建, 测试可以重复执行
+func runManage(t *testing.T, urls map[Organize]string) {
+	t.Helper()
+
+	initTasks()
+	for organize, url := range urls {
+		if err := SetWsUrl(organize, url); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ClockUrls = nil
+
 	Run()
+	t.Cleanup(Close)
+}
+
+func Test_Run(t *testing.T) {
+	okex := newMockExchange(t, OkEx)
+	huobi := newMockExchange(t, HuoBi)
+	runManage(t, map[Organize]string{
+		OkEx:               okex.url(),
+		HuoBi:              huobi.url(),
+		huoBiIndex:         huobi.url(),
+		huoBiNotify:        huobi.url(),
+		huoBiFuturesNotify: huobi.url(),
+	})
 
 	s := &Subscriber{
 		Symbol:     "ETH-USDT",
@@ -20,13 +44,18 @@ func Test_Run(t *testing.T) {
 		MarketType: SpotMarket,
 		Organize:   HuoBi,
 	}
-
 	WriteSubscribing <- h
 
-	for {
-		select {
-		case sub := <-ReadMarketPool:
-			fmt.Println(sub)
-		}
+	okex.waitSub(t, "spot/depth5:ETH-USDT")
+	huobi.waitSub(t, "market.ethusdt.depth.step1")
+
+	okex.push(`{"table":"spot/depth5","data":[{"asks":[["8.8","3"]],"bids":[["8.75","1"]],"instrument_id":"ETH-USDT","timestamp":"2020-03-01T08:00:00.123Z"}]}`)
+	huobi.push(`{"ch":"market.ethusdt.depth.step1","ts":1583049600123,"tick":{"bids":[[230.1,2]],"asks":[[230.2,1.5]],"version":100}}`)
+
+	//两个交易所的数据到达顺序不确定
+	waitMarketers(t, "ETH-USDT", "ethusdt")
+
+	if len(Find("okex", "ETH-USDT")) != 1 || len(Find("huobi", "ethusdt")) != 1 {
+		t.Fatal(Find("okex", "ETH-USDT"), Find("huobi", "ethusdt"))
 	}
 }